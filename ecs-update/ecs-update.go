@@ -121,9 +121,14 @@ func updatePackage(path, ecsVersion string) error {
 
 			// Only update sample event if a pipeline exists.
 			if ds.SampleEvent != nil {
-				_, err := ds.SampleEvent.SetSampleEventECSVersion(ecsVersion)
+				oldSampleEventVersion, err := ds.SampleEvent.SetSampleEventECSVersion(ecsVersion)
 				if err != nil {
 					log.Println("WARN:", pkg.Manifest.OriginalData.Name, "/", dataStreamName, ":", err)
+				} else {
+					// Keep fields files in lockstep with the sample event.
+					for _, f := range ds.Fields {
+						f.SetFieldsECSVersion(oldSampleEventVersion, ecsVersion)
+					}
 				}
 			}
 		}
@@ -142,6 +147,9 @@ func updatePackage(path, ecsVersion string) error {
 		if ds.SampleEvent != nil {
 			err = multierr.Append(err, WriteDocument(ds.SampleEvent, func(w io.Writer) error { return ds.SampleEvent.WriteJSON(w, 4) }))
 		}
+		for _, f := range ds.Fields {
+			err = multierr.Append(err, WriteDocument(f, f.WriteYAML))
+		}
 	}
 
 	if err != nil {
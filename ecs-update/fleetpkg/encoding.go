@@ -0,0 +1,77 @@
+package fleetpkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unicode/utf8"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CheckEncoding reports encoding issues in the file that break the YAML
+// decoder or downstream tooling: a leading UTF-8 byte order mark, bytes that
+// are not valid UTF-8, and CRLF line endings.
+func CheckEncoding(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if bytes.HasPrefix(data, utf8BOM) {
+		errs = append(errs, fmt.Errorf("%s: starts with a UTF-8 byte order mark", path))
+	}
+	if line := invalidUTF8Line(data); line > 0 {
+		errs = append(errs, fmt.Errorf("%s:%d: contains bytes that are not valid UTF-8", path, line))
+	}
+	if i := bytes.Index(data, []byte("\r\n")); i >= 0 {
+		errs = append(errs, fmt.Errorf("%s:%d: uses CRLF line endings", path, bytes.Count(data[:i], []byte("\n"))+1))
+	}
+	return errs
+}
+
+// FixEncoding removes a leading UTF-8 byte order mark and converts CRLF line
+// endings to LF. Invalid UTF-8 cannot be fixed without changing the content,
+// so the file is left unchanged and an error is returned.
+func FixEncoding(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if line := invalidUTF8Line(data); line > 0 {
+		return fmt.Errorf("%s:%d: contains bytes that are not valid UTF-8", path, line)
+	}
+
+	fixed := bytes.ReplaceAll(stripBOM(data), []byte("\r\n"), []byte("\n"))
+	if bytes.Equal(fixed, data) {
+		return nil
+	}
+	return writeRawYAML(path, fixed)
+}
+
+// stripBOM removes a leading UTF-8 byte order mark.
+func stripBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
+
+// invalidUTF8Line returns the line number of the first invalid UTF-8 byte, or
+// 0 if the data is valid.
+func invalidUTF8Line(data []byte) int {
+	if utf8.Valid(data) {
+		return 0
+	}
+	line := 1
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			return line
+		}
+		if r == '\n' {
+			line++
+		}
+		data = data[size:]
+	}
+	return 0
+}
+
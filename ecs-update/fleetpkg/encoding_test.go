@@ -0,0 +1,66 @@
+package fleetpkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckEncoding(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	clean := write("clean.yml", "name: my_package\nversion: 1.0.0\n")
+	assert.Empty(t, CheckEncoding(clean))
+
+	bom := write("bom.yml", "\ufeffname: my_package\nversion: 1.0.0\n")
+	errs := CheckEncoding(bom)
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], bom+": starts with a UTF-8 byte order mark")
+	}
+
+	crlf := write("crlf.yml", "name: my_package\nversion: 1.0.0\r\ntitle: My Package\r\n")
+	errs = CheckEncoding(crlf)
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], crlf+":2: uses CRLF line endings")
+	}
+
+	invalid := write("invalid.yml", "name: my_package\ntitle: caf\xe9\n")
+	errs = CheckEncoding(invalid)
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], invalid+":2: contains bytes that are not valid UTF-8")
+	}
+
+	assert.Len(t, CheckEncoding(filepath.Join(dir, "missing.yml")), 1)
+}
+
+func TestFixEncoding(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.yml")
+	require.NoError(t, os.WriteFile(path, []byte("\ufeffname: my_package\r\ntitle: Café\r\n"), 0o644))
+
+	doc, err := ReadYAMLDocument[Manifest](path)
+	require.NoError(t, err)
+	assert.Equal(t, "my_package", doc.OriginalData.Name)
+	assert.NotContains(t, string(doc.RawYAML), "\ufeff")
+
+	require.NoError(t, FixEncoding(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "name: my_package\ntitle: Café\n", string(data))
+	assert.Empty(t, CheckEncoding(path))
+
+	invalid := filepath.Join(dir, "invalid.yml")
+	require.NoError(t, os.WriteFile(invalid, []byte("title: caf\xe9\r\n"), 0o644))
+	assert.ErrorContains(t, FixEncoding(invalid), "not valid UTF-8")
+	data, err = os.ReadFile(invalid)
+	require.NoError(t, err)
+	assert.Equal(t, "title: caf\xe9\r\n", string(data))
+}
@@ -0,0 +1,119 @@
+package fleetpkg
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fields is the content of a data stream fields file (fields/*.yml).
+type Fields []map[string]interface{}
+
+// SetFieldsECSVersion replaces the old ECS version with the new version
+// wherever a fields file pins it. It updates comment lines that mention ECS
+// (e.g. "# ECS 8.2.0 fields") and the value of an ecs.version field
+// definition. It returns the number of references updated.
+func (doc *YAMLDocument[Fields]) SetFieldsECSVersion(old, version string) int {
+	if old == "" || old == version {
+		return 0
+	}
+
+	var updated int
+	lines := bytes.Split(doc.RawYAML, []byte("\n"))
+	for i, line := range lines {
+		trimmed := bytes.TrimSpace(line)
+		if !bytes.HasPrefix(trimmed, []byte("#")) {
+			continue
+		}
+		if !bytes.Contains(bytes.ToLower(trimmed), []byte("ecs")) || !bytes.Contains(trimmed, []byte(old)) {
+			continue
+		}
+		lines[i] = bytes.Replace(line, []byte(old), []byte(version), 1)
+		updated++
+	}
+	doc.RawYAML = bytes.Join(lines, []byte("\n"))
+
+	// Keep the node consistent with the raw YAML.
+	walkNodes(&doc.Node, func(n *yaml.Node) {
+		for _, c := range []*string{&n.HeadComment, &n.LineComment, &n.FootComment} {
+			if strings.Contains(strings.ToLower(*c), "ecs") {
+				*c = strings.ReplaceAll(*c, old, version)
+			}
+		}
+
+		if n.Kind != yaml.MappingNode || mappingValue(n, "name") != "ecs.version" {
+			return
+		}
+		if v := mappingNode(n, "value"); v != nil && v.Value == old {
+			v.Value = version
+			doc.RawYAML = ModifyLine(doc.RawYAML, v.Line, old, version)
+			updated++
+		}
+	})
+
+	return updated
+}
+
+// SetDataStreamECSVersion updates the ECS version in the data stream's sample
+// event and in any fields files that reference the sample event's previous
+// ECS version. The modified files are written back to disk. It returns the
+// previous ECS version.
+func SetDataStreamECSVersion(dataStreamDir, version string) (old string, err error) {
+	sampleEvent, err := ReadYAMLDocument[SampleEvent](filepath.Join(dataStreamDir, "sample_event.json"))
+	if err != nil {
+		return "", err
+	}
+
+	if old, err = sampleEvent.SetSampleEventECSVersion(version); err != nil {
+		return "", fmt.Errorf("failed updating %s: %w", sampleEvent.FilePath, err)
+	}
+	if err = writeRawYAML(sampleEvent.FilePath, sampleEvent.RawYAML); err != nil {
+		return "", err
+	}
+
+	fields, err := readFieldsFiles(dataStreamDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, doc := range fields {
+		if doc.SetFieldsECSVersion(old, version) == 0 {
+			continue
+		}
+		if err = writeRawYAML(doc.FilePath, doc.RawYAML); err != nil {
+			return "", err
+		}
+	}
+
+	return old, nil
+}
+
+func readFieldsFiles(dataStreamDir string) ([]*YAMLDocument[Fields], error) {
+	paths, err := filepath.Glob(filepath.Join(dataStreamDir, "fields/*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*YAMLDocument[Fields], 0, len(paths))
+	for _, path := range paths {
+		doc, err := ReadYAMLDocument[Fields](path)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func writeRawYAML(path string, data []byte) error {
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	return os.WriteFile(path, data, mode)
+}
@@ -0,0 +1,80 @@
+package fleetpkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyDir copies the src directory tree into dst.
+func copyDir(t *testing.T, src, dst string) {
+	t.Helper()
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode())
+	})
+	require.NoError(t, err)
+}
+
+func TestSetDataStreamECSVersion(t *testing.T) {
+	dsDir := t.TempDir()
+	copyDir(t, "testdata/my_package/data_stream/item_usages", dsDir)
+
+	pinned := `
+- name: ecs.version
+  type: keyword
+  value: 8.2.0 # Keep in sync with the pipeline.
+- name: other
+  type: keyword
+  value: 8.2.0
+`[1:]
+	require.NoError(t, os.WriteFile(filepath.Join(dsDir, "fields/pinned.yml"), []byte(pinned), 0o644))
+
+	unchanged, err := os.ReadFile(filepath.Join(dsDir, "fields/fields.yml"))
+	require.NoError(t, err)
+
+	old, err := SetDataStreamECSVersion(dsDir, "8.3.0")
+	require.NoError(t, err)
+	assert.Equal(t, "8.2.0", old)
+
+	sampleEvent, err := os.ReadFile(filepath.Join(dsDir, "sample_event.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(sampleEvent), "\"ecs\": {\n        \"version\": \"8.3.0\"\n    },")
+
+	ecsFields, err := os.ReadFile(filepath.Join(dsDir, "fields/ecs.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(ecsFields), "# Fields referenced from ECS 8.3.0.\n- external: ecs\n  name: ecs.version\n")
+
+	pinnedFields, err := os.ReadFile(filepath.Join(dsDir, "fields/pinned.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, `
+- name: ecs.version
+  type: keyword
+  value: 8.3.0 # Keep in sync with the pipeline.
+- name: other
+  type: keyword
+  value: 8.2.0
+`[1:], string(pinnedFields))
+
+	fields, err := os.ReadFile(filepath.Join(dsDir, "fields/fields.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, string(unchanged), string(fields))
+}
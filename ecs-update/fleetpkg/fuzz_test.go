@@ -0,0 +1,56 @@
+package fleetpkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func FuzzYAMLNodeToInterface(f *testing.F) {
+	sampleEvent, err := os.ReadFile("testdata/my_package/data_stream/item_usages/sample_event.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(sampleEvent)
+	f.Add([]byte("a: !!binary aGVsbG8=\nb: [1, two, 3.0, null, {c: d}]\n"))
+	f.Add([]byte("1: one\ntrue: yes\n~: nothing\n2.5: float\n"))
+	f.Add([]byte("- &a {x: 1}\n- *a\n- !custom tagged\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var n yaml.Node
+		if err := yaml.Unmarshal(data, &n); err != nil {
+			return
+		}
+
+		ifc, err := yamlNodeToInterface(&n)
+		if err != nil {
+			return
+		}
+
+		out, err := json.Marshal(ifc)
+		if err != nil {
+			// NaN and Inf have no JSON representation.
+			var unsupported *json.UnsupportedValueError
+			if errors.As(err, &unsupported) {
+				return
+			}
+			t.Fatalf("failed to marshal %#v: %v", ifc, err)
+		}
+
+		var decoded interface{}
+		if err = json.Unmarshal(out, &decoded); err != nil {
+			t.Fatalf("failed to decode %s: %v", out, err)
+		}
+		again, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, again) {
+			t.Fatalf("JSON changed after round-trip:\n%s\n%s", out, again)
+		}
+	})
+}
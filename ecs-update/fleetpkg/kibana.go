@@ -0,0 +1,54 @@
+package fleetpkg
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// KibanaSavedObject is a Kibana saved object (e.g. a dashboard) from the
+// package's kibana directory. The content is left untyped because it differs
+// between object types. Many attributes (e.g. panelsJSON) are strings that
+// contain JSON.
+type KibanaSavedObject map[string]interface{}
+
+// ReadKibanaSavedObjects reads the saved objects in the package's kibana/*
+// directories. Both JSON and YAML files are read.
+func ReadKibanaSavedObjects(packageDir string) ([]*YAMLDocument[KibanaSavedObject], error) {
+	var paths []string
+	for _, pattern := range []string{"kibana/*/*.json", "kibana/*/*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(packageDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	docs := make([]*YAMLDocument[KibanaSavedObject], 0, len(paths))
+	for _, path := range paths {
+		doc, err := ReadYAMLDocument[KibanaSavedObject](path)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// SetSavedObjectField replaces the existing scalar value at the dotted key
+// (e.g. "coreMigrationVersion") in the saved object file and writes it. Only
+// the value changes so the rest of the file is not reformatted. It returns
+// the old value.
+func SetSavedObjectField(path, key, value string) (old string, err error) {
+	doc, err := ReadYAMLDocument[KibanaSavedObject](path)
+	if err != nil {
+		return "", err
+	}
+	if old, err = doc.ReplaceField(key, value); err != nil {
+		return "", err
+	}
+	if err = writeRawYAML(path, doc.RawYAML); err != nil {
+		return "", err
+	}
+	return old, nil
+}
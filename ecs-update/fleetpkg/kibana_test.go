@@ -0,0 +1,46 @@
+package fleetpkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadKibanaSavedObjects(t *testing.T) {
+	docs, err := ReadKibanaSavedObjects("testdata/my_package")
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+
+	assert.Equal(t, "dashboard", docs[0].OriginalData["type"])
+	attrs := docs[0].OriginalData["attributes"].(KibanaSavedObject)
+	assert.Contains(t, attrs["panelsJSON"], `"version":"8.0.0"`)
+}
+
+func TestSetSavedObjectField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dashboard.json")
+	original, err := os.ReadFile("testdata/my_package/kibana/dashboard/1password-item-usages.json")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, original, 0o644))
+
+	old, err := SetSavedObjectField(path, "migrationVersion.dashboard", "8.5.0")
+	require.NoError(t, err)
+	assert.Equal(t, "8.0.0", old)
+
+	// Only the targeted value changes. The same value earlier on the line and
+	// in the embedded JSON is untouched.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(original),
+		`{"search": "8.0.0", "dashboard": "8.0.0"}`,
+		`{"search": "8.0.0", "dashboard": "8.5.0"}`, 1), string(data))
+
+	_, err = SetSavedObjectField(path, "attributes.version", "1")
+	assert.ErrorContains(t, err, "attributes.version not found")
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(after))
+}
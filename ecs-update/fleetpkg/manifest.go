@@ -1,5 +1,14 @@
 package fleetpkg
 
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/coreos/go-semver/semver"
+)
+
 type Manifest struct {
 	Name            string            `json:"name"`
 	Title           string            `json:"title"`
@@ -66,3 +75,60 @@ type PolicyTemplates struct {
 type Owner struct {
 	Github string `json:"github"`
 }
+
+// BumpPackageVersion sets the version in the package's manifest.yml and writes
+// the file, preserving its formatting. newVersion must be a valid semantic
+// version that is greater than the current version. It returns the old
+// version and the normalized new version that was written.
+func BumpPackageVersion(packageDir, newVersion string) (old, new string, err error) {
+	path := filepath.Join(packageDir, "manifest.yml")
+	doc, err := ReadYAMLDocument[Manifest](path)
+	if err != nil {
+		return "", "", err
+	}
+
+	next, err := semver.NewVersion(newVersion)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid package version %q: %w", newVersion, err)
+	}
+	current, err := semver.NewVersion(doc.OriginalData.Version)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid version %q in %s: %w", doc.OriginalData.Version, path, err)
+	}
+	if !current.LessThan(*next) {
+		return "", "", fmt.Errorf("package version %s is not greater than the current version %s", next, current)
+	}
+
+	new = next.String()
+	if old, err = doc.SetField("version", new); err != nil {
+		return "", "", err
+	}
+	if err = writeRawYAML(path, doc.RawYAML); err != nil {
+		return "", "", err
+	}
+	return old, new, nil
+}
+
+// defaultDataStreamType is the type of data streams that do not declare one.
+const defaultDataStreamType = "logs"
+
+// DataStreamType returns the type (e.g. logs, metrics, traces) declared in the
+// manifest.yml of the data stream directory. It returns "logs" if the
+// manifest does not declare a type.
+func DataStreamType(dataStreamDir string) (string, error) {
+	path := filepath.Join(dataStreamDir, "manifest.yml")
+	manifest, _, err := ReadYAMLDocumentWithNode[struct {
+		Type string `yaml:"type"`
+	}](path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("data stream manifest %s does not exist", path)
+		}
+		return "", err
+	}
+
+	if manifest.Type == "" {
+		return defaultDataStreamType, nil
+	}
+	return manifest.Type, nil
+}
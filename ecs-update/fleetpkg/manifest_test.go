@@ -1 +1,66 @@
 package fleetpkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBumpPackageVersion(t *testing.T) {
+	dir := t.TempDir()
+	copyDir(t, "testdata/my_package", dir)
+
+	original, err := os.ReadFile(filepath.Join(dir, "manifest.yml"))
+	require.NoError(t, err)
+
+	old, new, err := BumpPackageVersion(dir, "1.5.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.4.0", old)
+	assert.Equal(t, "1.5.0", new)
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(original), "version: 1.4.0", "version: 1.5.0", 1), string(data))
+
+	doc, err := ReadYAMLDocument[Manifest](filepath.Join(dir, "manifest.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.5.0", doc.OriginalData.Version)
+
+	for _, v := range []string{"1.5.0", "1.4.9", "not-a-version"} {
+		_, _, err = BumpPackageVersion(dir, v)
+		assert.Error(t, err, v)
+	}
+
+	// The version written is normalized.
+	old, new, err = BumpPackageVersion(dir, "1.06.0")
+	require.NoError(t, err)
+	assert.Equal(t, "1.5.0", old)
+	assert.Equal(t, "1.6.0", new)
+
+	doc, err = ReadYAMLDocument[Manifest](filepath.Join(dir, "manifest.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.6.0", doc.OriginalData.Version)
+}
+
+func TestDataStreamType(t *testing.T) {
+	typ, err := DataStreamType("testdata/two_data_streams/data_stream/access")
+	require.NoError(t, err)
+	assert.Equal(t, "logs", typ)
+
+	typ, err = DataStreamType("testdata/two_data_streams/data_stream/usage")
+	require.NoError(t, err)
+	assert.Equal(t, "metrics", typ)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.yml"), []byte("title: Untyped\n"), 0o644))
+	typ, err = DataStreamType(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "logs", typ)
+
+	_, err = DataStreamType(filepath.Join(dir, "missing"))
+	assert.ErrorContains(t, err, "manifest.yml does not exist")
+}
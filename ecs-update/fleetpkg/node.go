@@ -0,0 +1,203 @@
+package fleetpkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CloneNode returns a deep copy of n including its comments, style, and
+// position information. Aliases in the copy refer to the copied anchors.
+func CloneNode(n *yaml.Node) *yaml.Node {
+	return cloneNode(n, map[*yaml.Node]*yaml.Node{})
+}
+
+func cloneNode(n *yaml.Node, cloned map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	if c, found := cloned[n]; found {
+		return c
+	}
+
+	c := &yaml.Node{}
+	*c = *n
+	cloned[n] = c
+
+	if n.Content != nil {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = cloneNode(child, cloned)
+		}
+	}
+	c.Alias = cloneNode(n.Alias, cloned)
+
+	return c
+}
+
+// walkNodes calls fn for n and all nodes below it.
+func walkNodes(n *yaml.Node, fn func(*yaml.Node)) {
+	fn(n)
+	for _, c := range n.Content {
+		walkNodes(c, fn)
+	}
+}
+
+// SortMappingKeys sorts the key/value pairs of the mapping node n by key.
+// Comments are attached to the key and value nodes so they move with their
+// pairs. A document node is not itself reordered but its root is sorted. If
+// recursive is true then mappings nested within n, including those in
+// sequences, are sorted too. Other nodes and aliases are left untouched.
+func SortMappingKeys(n *yaml.Node, recursive bool) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			SortMappingKeys(c, recursive)
+		}
+	case yaml.MappingNode:
+		pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			pairs = append(pairs, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i][0].Value < pairs[j][0].Value
+		})
+		for i, p := range pairs {
+			n.Content[2*i], n.Content[2*i+1] = p[0], p[1]
+			if recursive {
+				SortMappingKeys(p[1], recursive)
+			}
+		}
+	case yaml.SequenceNode:
+		if recursive {
+			for _, c := range n.Content {
+				SortMappingKeys(c, recursive)
+			}
+		}
+	}
+}
+
+// mappingNode returns the value node for key in the mapping node n.
+func mappingNode(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the scalar value for key in the mapping node n.
+func mappingValue(n *yaml.Node, key string) string {
+	if v := mappingNode(n, key); v != nil && v.Kind == yaml.ScalarNode {
+		return v.Value
+	}
+	return ""
+}
+
+// ReplaceField replaces the existing scalar value at the dotted key and
+// returns the previous value. Unlike SetField it never creates the key, so
+// only the value's bytes in RawYAML change and the rest of the file, including
+// strings containing embedded JSON, is left exactly as it was.
+func (doc *YAMLDocument[any]) ReplaceField(key, value string) (old string, err error) {
+	if _, found := doc.GetField(key); !found {
+		return "", fmt.Errorf("%s not found", key)
+	}
+	return doc.SetField(key, value)
+}
+
+// ModifyNode replaces the scalar node's value in content, which is the source
+// the node was decoded from. The node itself is not modified. Unlike
+// ModifyLine it starts searching at the node's column so that an identical
+// value earlier on the same line (e.g. in single-line JSON) is not replaced.
+func ModifyNode(content []byte, n *yaml.Node, value string) []byte {
+	if n.Value == value {
+		return content
+	}
+
+	lines := bytes.SplitN(content, []byte("\n"), n.Line)
+	if len(lines) < n.Line {
+		return content
+	}
+	line := lines[n.Line-1]
+
+	// Columns count characters, not bytes.
+	offset := 0
+	for col := 1; col < n.Column && offset < len(line); col++ {
+		_, size := utf8.DecodeRune(line[offset:])
+		offset += size
+	}
+	idx := bytes.Index(line[offset:], []byte(n.Value))
+	if idx < 0 {
+		return content
+	}
+	idx += offset
+
+	modified := make([]byte, 0, len(line)+len(value)-len(n.Value))
+	modified = append(modified, line[:idx]...)
+	modified = append(modified, value...)
+	modified = append(modified, line[idx+len(n.Value):]...)
+	lines[n.Line-1] = modified
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// SetField sets the scalar value at the dotted key (e.g. "ecs.version") and
+// returns the previous value. Objects missing along the key are created. An
+// existing value is replaced in RawYAML in place so that formatting is
+// preserved. If anything was created then RawYAML is re-encoded from the node
+// (as JSON for .json files).
+func (doc *YAMLDocument[any]) SetField(key, value string) (old string, err error) {
+	if len(doc.Node.Content) == 0 {
+		return "", errors.New("document is empty")
+	}
+
+	parts := strings.Split(key, ".")
+	n := doc.Node.Content[0]
+	var created bool
+	for i, part := range parts {
+		if n.Kind != yaml.MappingNode {
+			return "", fmt.Errorf("%s is not an object", strings.Join(parts[:i], "."))
+		}
+
+		child := mappingNode(n, part)
+		if child == nil {
+			if i == len(parts)-1 {
+				child = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+			} else {
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, child)
+			created = true
+		}
+		n = child
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("%s is not a scalar", key)
+	}
+
+	old = n.Value
+	if !created {
+		doc.RawYAML = ModifyNode(doc.RawYAML, n, value)
+		n.Value = value
+		return old, nil
+	}
+	n.Value = value
+
+	var buf bytes.Buffer
+	if filepath.Ext(doc.FilePath) == ".json" {
+		err = doc.WriteJSON(&buf, 4)
+	} else {
+		err = doc.WriteYAML(&buf)
+	}
+	if err != nil {
+		return "", err
+	}
+	doc.RawYAML = buf.Bytes()
+	return old, nil
+}
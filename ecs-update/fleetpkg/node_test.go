@@ -0,0 +1,102 @@
+package fleetpkg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func encodeNode(t *testing.T, n *yaml.Node) string {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	require.NoError(t, enc.Encode(n))
+	require.NoError(t, enc.Close())
+	return buf.String()
+}
+
+func TestCloneNode(t *testing.T) {
+	doc, err := ReadYAMLDocument[IngestNodePipeline]("testdata/my_package/data_stream/item_usages/elasticsearch/ingest_pipeline/default.yml")
+	require.NoError(t, err)
+
+	clone := CloneNode(&doc.Node)
+	original := encodeNode(t, &doc.Node)
+	assert.Equal(t, original, encodeNode(t, clone))
+
+	_, err = doc.SetIngestNodePipelineECSVersion("8.3.0")
+	require.NoError(t, err)
+	doc.Node.Content[0].HeadComment = "# Modified."
+
+	assert.NotEqual(t, original, encodeNode(t, &doc.Node))
+	assert.Equal(t, original, encodeNode(t, clone))
+}
+
+func TestCloneNodeAlias(t *testing.T) {
+	var n yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("a: &x {b: 1}\nc: *x\n"), &n))
+
+	clone := CloneNode(&n)
+	mapping := clone.Content[0]
+	assert.Same(t, mapping.Content[1], mapping.Content[3].Alias)
+	assert.NotSame(t, n.Content[0].Content[1], mapping.Content[1])
+}
+
+func TestSortMappingKeys(t *testing.T) {
+	const input = `---
+# Package name.
+name: example
+# Package title.
+title: Example
+conditions:
+  kibana.version: ^8.0.0 # Minimum version.
+  elastic.subscription: basic
+policy_templates:
+  - title: Logs
+    name: logs
+description: An example.
+`
+
+	sorted := func(recursive bool) string {
+		var n yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte(input), &n))
+		SortMappingKeys(&n, recursive)
+		return encodeNode(t, &n)
+	}
+
+	assert.Equal(t, `conditions:
+  kibana.version: ^8.0.0 # Minimum version.
+  elastic.subscription: basic
+description: An example.
+# Package name.
+name: example
+policy_templates:
+  - title: Logs
+    name: logs
+# Package title.
+title: Example
+`, sorted(false))
+
+	assert.Equal(t, `conditions:
+  elastic.subscription: basic
+  kibana.version: ^8.0.0 # Minimum version.
+description: An example.
+# Package name.
+name: example
+policy_templates:
+  - name: logs
+    title: Logs
+# Package title.
+title: Example
+`, sorted(true))
+
+	// Non-mapping nodes are untouched.
+	var seq yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("[b, a]\n"), &seq))
+	SortMappingKeys(&seq, true)
+	assert.Equal(t, "[b, a]\n", encodeNode(t, &seq))
+}
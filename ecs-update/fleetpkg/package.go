@@ -8,8 +8,10 @@ import (
 	"io"
 	"io/fs"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/vmware-labs/yaml-jsonpath/pkg/yamlpath"
 	"gopkg.in/yaml.v3"
@@ -22,11 +24,12 @@ type YAMLDocument[T any] struct {
 	OriginalData T
 }
 
-func ReadYAMLDocument[T Manifest | BuildManifest | IngestNodePipeline | SampleEvent](path string) (*YAMLDocument[T], error) {
+func ReadYAMLDocument[T Manifest | BuildManifest | IngestNodePipeline | SampleEvent | Fields | TestConfig | KibanaSavedObject](path string) (*YAMLDocument[T], error) {
 	yamlData, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	yamlData = stripBOM(yamlData)
 
 	doc := &YAMLDocument[T]{
 		FilePath: path,
@@ -44,6 +47,31 @@ func ReadYAMLDocument[T Manifest | BuildManifest | IngestNodePipeline | SampleEv
 	return doc, nil
 }
 
+// ReadYAMLDocumentWithNode reads the YAML (or JSON) file and returns both the
+// value decoded into T and the document's root node. Unlike ReadYAMLDocument
+// it accepts any T, so the node can be edited for types that do not have a
+// YAMLDocument wrapper.
+func ReadYAMLDocumentWithNode[T any](path string) (T, *yaml.Node, error) {
+	var v T
+
+	yamlData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return v, nil, err
+	}
+	yamlData = stripBOM(yamlData)
+
+	var node yaml.Node
+	if err = yaml.Unmarshal(yamlData, &node); err != nil {
+		return v, nil, fmt.Errorf("failed reading from %q: %w", path, err)
+	}
+
+	if err = node.Decode(&v); err != nil {
+		return v, nil, fmt.Errorf("failed decoding %q: %w", path, err)
+	}
+
+	return v, &node, nil
+}
+
 func (doc *YAMLDocument[any]) WriteYAML(w io.Writer) error {
 	enc := yaml.NewEncoder(w)
 	enc.SetIndent(2)
@@ -89,6 +117,7 @@ type Package struct {
 type DataStream struct {
 	DefaultPipeline *YAMLDocument[IngestNodePipeline] `json:"default_pipeline,omitempty"` // Optional
 	SampleEvent     *YAMLDocument[SampleEvent]        `json:"sample_event,omitempty"`     // Optional
+	Fields          []*YAMLDocument[Fields]           `json:"fields,omitempty"`
 }
 
 func ReadPackage(path string) (*Package, error) {
@@ -102,8 +131,8 @@ func ReadPackage(path string) (*Package, error) {
 		return nil, err
 	}
 
-	dataStreamList, err := filepath.Glob(filepath.Join(path, "data_stream/*"))
-	if err != nil {
+	dataStreamList, err := ListDataStreams(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 
@@ -113,8 +142,8 @@ func ReadPackage(path string) (*Package, error) {
 		DataStreams:   make(map[string]DataStream, len(dataStreamList)),
 	}
 
-	for _, dsPath := range dataStreamList {
-		dataStreamName := filepath.Base(dsPath)
+	for _, dataStreamName := range dataStreamList {
+		dsPath := filepath.Join(path, "data_stream", dataStreamName)
 
 		pipeline, err := ReadYAMLDocument[IngestNodePipeline](filepath.Join(dsPath, "elasticsearch/ingest_pipeline/default.yml"))
 		if err != nil && !errors.Is(err, fs.ErrNotExist) {
@@ -126,15 +155,39 @@ func ReadPackage(path string) (*Package, error) {
 			return nil, err
 		}
 
+		fields, err := readFieldsFiles(dsPath)
+		if err != nil {
+			return nil, err
+		}
+
 		pkg.DataStreams[dataStreamName] = DataStream{
 			DefaultPipeline: pipeline,
 			SampleEvent:     sampleEvent,
+			Fields:          fields,
 		}
 	}
 
 	return pkg, nil
 }
 
+// ListDataStreams returns the names of the data stream directories contained
+// in the package's data_stream directory. Files and hidden entries are ignored.
+func ListDataStreams(packageDir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(packageDir, "data_stream"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
 func mustYAMLPath(path string) *yamlpath.Path {
 	p, err := yamlpath.NewPath(path)
 	if err != nil {
@@ -146,16 +199,31 @@ func mustYAMLPath(path string) *yamlpath.Path {
 // FixYAMLMaps recursively converts maps with interface{} keys, as returned by
 // yaml.Unmarshal, to maps of string keys, as expected by the json encoder
 // that will be used when delivering the pipeline to Elasticsearch.
-// Will return an error when something other than a string is used as a key.
+// Scalar keys (e.g. numbers, booleans, timestamps, and null) are converted to strings.
+// Will return an error when any other key is used or when two keys convert to
+// the same string.
 func fixYAMLMaps(elem interface{}) (_ interface{}, err error) {
 	switch v := elem.(type) {
 	case map[interface{}]interface{}:
 		result := make(map[string]interface{}, len(v))
 		for key, value := range v {
-			keyS, ok := key.(string)
-			if !ok {
+			var keyS string
+			switch k := key.(type) {
+			case string:
+				keyS = k
+			case nil:
+				keyS = "null"
+			case bool, int, int64, uint64, float64:
+				keyS = fmt.Sprint(k)
+			case time.Time:
+				// Match the json encoding of time values.
+				keyS = k.Format(time.RFC3339Nano)
+			default:
 				return nil, fmt.Errorf("key '%v' is not string but %T", key, key)
 			}
+			if _, found := result[keyS]; found {
+				return nil, fmt.Errorf("duplicate key '%v' after conversion to string", key)
+			}
 			if result[keyS], err = fixYAMLMaps(value); err != nil {
 				return nil, err
 			}
@@ -1,12 +1,14 @@
 package fleetpkg
 
 import (
+	"io/fs"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vmware-labs/yaml-jsonpath/pkg/yamlpath"
+	"gopkg.in/yaml.v3"
 )
 
 func TestReadYAMLDocument(t *testing.T) {
@@ -67,3 +69,55 @@ func TestEditPackage(t *testing.T) {
 		ds.SampleEvent.WriteJSON(os.Stdout, 2)
 	}
 }
+
+func TestListDataStreams(t *testing.T) {
+	dataStreams, err := ListDataStreams("testdata/two_data_streams")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"access", "usage"}, dataStreams)
+
+	_, err = ListDataStreams("testdata/does_not_exist")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestReadYAMLDocumentWithNode(t *testing.T) {
+	type nameVersion struct {
+		Name    string `yaml:"name"`
+		Version string `yaml:"version"`
+	}
+
+	v, node, err := ReadYAMLDocumentWithNode[nameVersion]("testdata/my_package/manifest.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "1password", v.Name)
+	assert.Equal(t, "1.4.0", v.Version)
+
+	p, err := yamlpath.NewPath("$.version")
+	require.NoError(t, err)
+
+	nodes, err := p.Find(node)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, v.Version, nodes[0].Value)
+
+	ifc, err := yamlNodeToInterface(node)
+	require.NoError(t, err)
+	assert.Equal(t, v.Name, ifc.(map[string]interface{})["name"])
+}
+
+func TestYAMLNodeToInterfaceNonStringKeys(t *testing.T) {
+	var n yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte("1: one\ntrue: yes\n~: nothing\n2.5: {3: three}\n2001-12-14: date\n"), &n))
+
+	ifc, err := yamlNodeToInterface(&n)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"1":                    "one",
+		"true":                 "yes",
+		"null":                 "nothing",
+		"2.5":                  map[string]interface{}{"3": "three"},
+		"2001-12-14T00:00:00Z": "date",
+	}, ifc)
+
+	require.NoError(t, yaml.Unmarshal([]byte("1: int\n1.0: float\n"), &n))
+	_, err = yamlNodeToInterface(&n)
+	assert.ErrorContains(t, err, "duplicate key")
+}
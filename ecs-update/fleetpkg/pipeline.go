@@ -35,6 +35,41 @@ func (p *Processor) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// ReadPipeline reads an ingest pipeline file. The document's Node retains
+// comments and block scalars (e.g. multiline Painless scripts) so the
+// pipeline can be modified and written without mangling them.
+func ReadPipeline(path string) (*YAMLDocument[IngestNodePipeline], error) {
+	return ReadYAMLDocument[IngestNodePipeline](path)
+}
+
+// PipelineProcessor is a processor within a pipeline document.
+type PipelineProcessor struct {
+	Type string     // Processor type (e.g. script).
+	Node *yaml.Node // Processor configuration. Changes are retained in the document's Node.
+}
+
+// Processors returns the pipeline's processors in order. It does not include
+// the on_failure processors.
+func (doc *YAMLDocument[IngestNodePipeline]) Processors() []PipelineProcessor {
+	if len(doc.Node.Content) == 0 {
+		return nil
+	}
+
+	list := mappingNode(doc.Node.Content[0], "processors")
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	processors := make([]PipelineProcessor, 0, len(list.Content))
+	for _, p := range list.Content {
+		if p.Kind != yaml.MappingNode || len(p.Content) < 2 {
+			continue
+		}
+		processors = append(processors, PipelineProcessor{Type: p.Content[0].Value, Node: p.Content[1]})
+	}
+	return processors
+}
+
 func (doc *YAMLDocument[IngestNodePipeline]) SetIngestNodePipelineECSVersion(version string) (old string, err error) {
 	nodes, _ := ingestNodePipelineSetECSVersionValuePath.Find(&doc.Node)
 	if len(nodes) == 0 {
@@ -28,3 +28,34 @@ func TestIngestNodePipeline(t *testing.T) {
 
 	assert.Equal(t, strings.Replace(string(doc.RawYAML), `value: "8.2.0"`, `value: "8.3.0"`, 1), buf.String())
 }
+
+func TestReadPipeline(t *testing.T) {
+	const path = "testdata/pipelines/painless.yml"
+
+	doc, err := ReadPipeline(path)
+	require.NoError(t, err)
+
+	processors := doc.Processors()
+	require.Len(t, processors, 3)
+	assert.Equal(t, "grok", processors[0].Type)
+	assert.Equal(t, "script", processors[1].Type)
+	assert.Equal(t, "set", processors[2].Type)
+	assert.Equal(t, "script", doc.OriginalData.Processors[1].Type)
+
+	script := mappingNode(processors[1].Node, "source")
+	require.NotNil(t, script)
+	assert.Equal(t, yaml.LiteralStyle, script.Style)
+	assert.Contains(t, script.Value, "handleMap(ctx);\n")
+
+	// Comments, block scalars, and quoting survive a round-trip.
+	buf := new(bytes.Buffer)
+	require.NoError(t, doc.WriteYAML(buf))
+	assert.Equal(t, string(doc.RawYAML), buf.String())
+
+	// Edits made through the processor nodes are written.
+	script.Value = strings.Replace(script.Value, "handleMap(ctx);", "handleMap(ctx);\nctx.remove('_tmp');", 1)
+	buf.Reset()
+	require.NoError(t, doc.WriteYAML(buf))
+	assert.Contains(t, buf.String(), "        handleMap(ctx);\n        ctx.remove('_tmp');\n")
+	assert.Contains(t, buf.String(), "  # Parse the syslog header.\n")
+}
@@ -1,23 +1,104 @@
 package fleetpkg
 
-import "errors"
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
 
 var sampleEventECSVersionPath = mustYAMLPath("$.ecs.version")
 
 type SampleEvent map[string]interface{}
 
+// SetSampleEventECSVersion replaces ecs.version in the sample event. It
+// returns an error, leaving the sample event unchanged, if ecs.version does
+// not exist.
 func (doc *YAMLDocument[BuildManifest]) SetSampleEventECSVersion(version string) (old string, err error) {
-	nodes, _ := sampleEventECSVersionPath.Find(&doc.Node)
-	if len(nodes) == 0 {
-		return "", errors.New("ecs.version not found in sample event")
-	} else if len(nodes) > 1 {
-		return "", errors.New("expected only one match")
-	}
+	return doc.SetECSVersionAt("ecs.version", version)
+}
 
-	node := nodes[0]
-	old = node.Value
-	node.Value = version
-	doc.RawYAML = ModifyLine(doc.RawYAML, node.Line, old, version)
+// SetECSVersionAt replaces the ECS version stored at the dotted path (e.g.
+// "observer.version"). It returns an error, leaving the sample event
+// unchanged, if the path does not exist.
+func (doc *YAMLDocument[BuildManifest]) SetECSVersionAt(path, version string) (old string, err error) {
+	if _, found := doc.GetField(path); !found {
+		return "", fmt.Errorf("%s not found in sample event", path)
+	}
+	return doc.SetOrCreateECSVersionAt(path, version)
+}
 
+// SetOrCreateECSVersionAt is like SetECSVersionAt but it creates the path if
+// it does not exist. Creating the path re-encodes the whole sample event (see
+// SetField).
+func (doc *YAMLDocument[BuildManifest]) SetOrCreateECSVersionAt(path, version string) (old string, err error) {
+	if old, err = doc.SetField(path, version); err != nil {
+		return "", fmt.Errorf("failed setting ECS version at %s: %w", path, err)
+	}
 	return old, nil
 }
+
+// CheckECSVersions returns the paths of the package's sample events whose
+// ecs.version differs from the expected version. Sample events without an
+// ecs.version are included. Data streams without a sample event are ignored.
+func CheckECSVersions(packageDir, expected string) ([]string, error) {
+	dataStreams, err := ListDataStreams(packageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatched []string
+	for _, ds := range dataStreams {
+		path := filepath.Join(packageDir, "data_stream", ds, "sample_event.json")
+
+		doc, err := ReadYAMLDocument[SampleEvent](path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed reading sample event: %w", err)
+		}
+
+		nodes, err := sampleEventECSVersionPath.Find(&doc.Node)
+		if err != nil {
+			return nil, err
+		}
+		if len(nodes) != 1 || nodes[0].Value != expected {
+			mismatched = append(mismatched, path)
+		}
+	}
+	return mismatched, nil
+}
+
+// FormatSampleEvent rewrites the sample event at path as JSON indented with
+// four spaces. Key order is preserved unless sortKeys is true, in which case
+// the keys of all objects are sorted. It returns an error without modifying
+// the file if the sample event is not valid JSON.
+func FormatSampleEvent(path string, sortKeys bool) error {
+	doc, err := ReadYAMLDocument[SampleEvent](path)
+	if err != nil {
+		return fmt.Errorf("failed reading sample event: %w", err)
+	}
+	if !json.Valid(doc.RawYAML) {
+		return fmt.Errorf("sample event %s is not valid JSON", path)
+	}
+
+	var buf bytes.Buffer
+	if sortKeys {
+		// The JSON encoder sorts the keys of maps.
+		err = doc.WriteJSON(&buf, 4)
+	} else {
+		var compact bytes.Buffer
+		if err = json.Compact(&compact, doc.RawYAML); err == nil {
+			err = json.Indent(&buf, compact.Bytes(), "", "    ")
+			buf.WriteByte('\n')
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed formatting sample event %s: %w", path, err)
+	}
+
+	return writeRawYAML(path, buf.Bytes())
+}
@@ -2,6 +2,8 @@ package fleetpkg
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -26,3 +28,154 @@ func TestSampleEvent(t *testing.T) {
     "version": "8.3.0"
   },`))
 }
+
+func TestCheckECSVersions(t *testing.T) {
+	stale, err := CheckECSVersions("testdata/two_data_streams", "8.3.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdata/two_data_streams/data_stream/usage/sample_event.json"}, stale)
+
+	stale, err = CheckECSVersions("testdata/two_data_streams", "8.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testdata/two_data_streams/data_stream/access/sample_event.json"}, stale)
+}
+
+func TestSetECSVersionAt(t *testing.T) {
+	const path = "testdata/my_package/data_stream/item_usages/sample_event.json"
+
+	t.Run("default", func(t *testing.T) {
+		doc, err := ReadYAMLDocument[SampleEvent](path)
+		require.NoError(t, err)
+
+		old, err := doc.SetSampleEventECSVersion("8.3.0")
+		require.NoError(t, err)
+		assert.Equal(t, "8.2.0", old)
+		assert.Contains(t, string(doc.RawYAML), `"version": "8.3.0"`)
+		assert.NotContains(t, string(doc.RawYAML), `"version": "8.2.0"`)
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		doc, err := ReadYAMLDocument[SampleEvent](path)
+		require.NoError(t, err)
+
+		old, err := doc.SetECSVersionAt("agent.version", "8.3.0")
+		require.NoError(t, err)
+		assert.Equal(t, "8.0.0", old)
+
+		nodes, err := mustYAMLPath("$.agent.version").Find(&doc.Node)
+		require.NoError(t, err)
+		require.Len(t, nodes, 1)
+		assert.Equal(t, "8.3.0", nodes[0].Value)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		doc, err := ReadYAMLDocument[SampleEvent](path)
+		require.NoError(t, err)
+
+		old, err := doc.SetOrCreateECSVersionAt("_meta.schema.ecs_version", "8.3.0")
+		require.NoError(t, err)
+		assert.Empty(t, old)
+
+		var event map[string]interface{}
+		require.NoError(t, json.Unmarshal(doc.RawYAML, &event))
+		assert.Equal(t, map[string]interface{}{"schema": map[string]interface{}{"ecs_version": "8.3.0"}}, event["_meta"])
+		assert.Equal(t, map[string]interface{}{"version": "8.2.0"}, event["ecs"])
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		dir := t.TempDir()
+		original := []byte(`{"message": "hello", "@timestamp": "2022-07-27T12:00:00.000Z", "event": {"kind": "event"}}`)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "sample_event.json"), original, 0o644))
+
+		_, err := SetDataStreamECSVersion(dir, "8.3.0")
+		assert.ErrorContains(t, err, "ecs.version not found in sample event")
+
+		data, err := os.ReadFile(filepath.Join(dir, "sample_event.json"))
+		require.NoError(t, err)
+		assert.Equal(t, string(original), string(data))
+
+		doc, err := ReadYAMLDocument[SampleEvent](filepath.Join(dir, "sample_event.json"))
+		require.NoError(t, err)
+		_, err = doc.SetECSVersionAt("_meta.ecs_version", "8.3.0")
+		assert.EqualError(t, err, "_meta.ecs_version not found in sample event")
+		assert.Equal(t, string(original), string(doc.RawYAML))
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		doc, err := ReadYAMLDocument[SampleEvent](path)
+		require.NoError(t, err)
+
+		_, err = doc.SetOrCreateECSVersionAt("ecs.version.major", "8")
+		assert.ErrorContains(t, err, "ecs.version is not an object")
+	})
+}
+
+func TestFormatSampleEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample_event.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"event": {"kind": "metric"}, "@timestamp": "2022-07-27T12:00:00.000Z",
+  "ecs": {"version": "8.2.0"}, "message": "<a & b>", "tags": ["z", "a"]}`), 0o644))
+
+	doc, err := ReadYAMLDocument[SampleEvent](path)
+	require.NoError(t, err)
+	_, err = doc.SetSampleEventECSVersion("8.3.0")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, doc.RawYAML, 0o644))
+
+	require.NoError(t, FormatSampleEvent(path, false))
+	formatted, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+    "event": {
+        "kind": "metric"
+    },
+    "@timestamp": "2022-07-27T12:00:00.000Z",
+    "ecs": {
+        "version": "8.3.0"
+    },
+    "message": "<a & b>",
+    "tags": [
+        "z",
+        "a"
+    ]
+}
+`, string(formatted))
+
+	// Formatting is idempotent.
+	require.NoError(t, FormatSampleEvent(path, false))
+	again, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(formatted), string(again))
+
+	require.NoError(t, FormatSampleEvent(path, true))
+	sorted, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{
+    "@timestamp": "2022-07-27T12:00:00.000Z",
+    "ecs": {
+        "version": "8.3.0"
+    },
+    "event": {
+        "kind": "metric"
+    },
+    "message": "<a & b>",
+    "tags": [
+        "z",
+        "a"
+    ]
+}
+`, string(sorted))
+
+	require.NoError(t, FormatSampleEvent(path, true))
+	again, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(sorted), string(again))
+}
+
+func TestFormatSampleEventInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample_event.json")
+	require.NoError(t, os.WriteFile(path, []byte("event:\n  kind: metric\n"), 0o644))
+
+	assert.ErrorContains(t, FormatSampleEvent(path, false), "not valid JSON")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "event:\n  kind: metric\n", string(data))
+}
@@ -0,0 +1,87 @@
+package fleetpkg
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestConfig is a test configuration from a _dev/test directory (e.g.
+// _dev/test/system/test-default-config.yml). The formats differ between test
+// types so the content is left untyped. Use GetField and SetField to access
+// values.
+type TestConfig map[string]interface{}
+
+// ReadTestConfigs reads the YAML test configurations of every test type under
+// the _dev/test directory of a package or data stream. It returns nothing if
+// there are no test configurations.
+func ReadTestConfigs(dir string) ([]*YAMLDocument[TestConfig], error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "_dev/test/*/*.yml"))
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*YAMLDocument[TestConfig], 0, len(paths))
+	for _, path := range paths {
+		doc, err := ReadYAMLDocument[TestConfig](path)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// TestType returns the type of test (e.g. system, pipeline) that the
+// configuration is for. It is the name of the directory containing the file.
+func (doc *YAMLDocument[TestConfig]) TestType() string {
+	return filepath.Base(filepath.Dir(doc.FilePath))
+}
+
+// GetField returns the scalar value at the dotted key (e.g. "vars.preserve").
+func (doc *YAMLDocument[any]) GetField(key string) (value string, found bool) {
+	if len(doc.Node.Content) == 0 {
+		return "", false
+	}
+
+	n := doc.Node.Content[0]
+	for _, part := range strings.Split(key, ".") {
+		if n.Kind != yaml.MappingNode {
+			return "", false
+		}
+		if n = mappingNode(n, part); n == nil {
+			return "", false
+		}
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", false
+	}
+	return n.Value, true
+}
+
+// UpdateTestConfigs sets the dotted key to value in each test configuration
+// under dir that already contains the key, preserving the formatting of the
+// files. It returns the paths of the files that were changed.
+func UpdateTestConfigs(dir, key, value string) ([]string, error) {
+	docs, err := ReadTestConfigs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, doc := range docs {
+		old, found := doc.GetField(key)
+		if !found || old == value {
+			continue
+		}
+		if _, err = doc.SetField(key, value); err != nil {
+			return nil, err
+		}
+		if err = writeRawYAML(doc.FilePath, doc.RawYAML); err != nil {
+			return nil, err
+		}
+		changed = append(changed, doc.FilePath)
+	}
+	return changed, nil
+}
@@ -0,0 +1,56 @@
+package fleetpkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTestConfigs(t *testing.T) {
+	docs, err := ReadTestConfigs("testdata/my_package/data_stream/item_usages")
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+
+	assert.Equal(t, "pipeline", docs[0].TestType())
+	assert.Equal(t, "system", docs[1].TestType())
+
+	v, found := docs[1].GetField("data_stream.vars.limit")
+	assert.True(t, found)
+	assert.Equal(t, "1000", v)
+	assert.Equal(t, "httpjson", docs[1].OriginalData["input"])
+
+	_, found = docs[1].GetField("data_stream.vars")
+	assert.False(t, found)
+	_, found = docs[0].GetField("data_stream.vars.limit")
+	assert.False(t, found)
+
+	docs, err = ReadTestConfigs("testdata/two_data_streams")
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func TestUpdateTestConfigs(t *testing.T) {
+	dir := t.TempDir()
+	copyDir(t, "testdata/my_package/data_stream/item_usages", dir)
+
+	systemPath := filepath.Join(dir, "_dev/test/system/test-default-config.yml")
+	original, err := os.ReadFile(systemPath)
+	require.NoError(t, err)
+
+	changed, err := UpdateTestConfigs(dir, "data_stream.vars.preserve_original_event", "true")
+	require.NoError(t, err)
+	assert.Equal(t, []string{systemPath}, changed)
+
+	data, err := os.ReadFile(systemPath)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(string(original), "preserve_original_event: false", "preserve_original_event: true", 1), string(data))
+
+	// Nothing changes when the value is already set.
+	changed, err = UpdateTestConfigs(dir, "data_stream.vars.preserve_original_event", "true")
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
@@ -33,16 +33,45 @@ func init() {
 }
 
 type Field struct {
-	DashedName  string        `yaml:"dashed_name"`
-	Description string        `yaml:"description"`
-	Example     string        `yaml:"example"`
-	FlatName    string        `yaml:"flat_name"`
-	IgnoreAbove int           `yaml:"ignore_above"`
-	Level       string        `yaml:"level"`
-	Name        string        `yaml:"name"`
-	Normalize   []interface{} `yaml:"normalize"`
-	Short       string        `yaml:"short"`
-	Type        string        `yaml:"type"`
+	AllowedValues   []AllowedValue `yaml:"allowed_values"`
+	Beta            string         `yaml:"beta"`
+	DashedName      string         `yaml:"dashed_name"`
+	Deprecated      string         `yaml:"deprecated"`
+	Description     string         `yaml:"description"`
+	Dimension       bool           `yaml:"dimension"`
+	DocValues       *bool          `yaml:"doc_values"`
+	Example         string         `yaml:"example"`
+	FlatName        string         `yaml:"flat_name"`
+	Format          string         `yaml:"format"`
+	IgnoreAbove     int            `yaml:"ignore_above"`
+	InputFormat     string         `yaml:"input_format"`
+	Level           string         `yaml:"level"`
+	MetricType      string         `yaml:"metric_type"`
+	MultiFields     []MultiField   `yaml:"multi_fields"`
+	Name            string         `yaml:"name"`
+	Normalize       []interface{}  `yaml:"normalize"`
+	ObjectType      string         `yaml:"object_type"`
+	OutputFormat    string         `yaml:"output_format"`
+	OutputPrecision int            `yaml:"output_precision"`
+	Path            string         `yaml:"path"`
+	ScalingFactor   int            `yaml:"scaling_factor"`
+	Short           string         `yaml:"short"`
+	Type            string         `yaml:"type"`
+}
+
+// AllowedValue is one of the values expected for a categorization field.
+type AllowedValue struct {
+	Name               string   `yaml:"name"`
+	Description        string   `yaml:"description"`
+	ExpectedEventTypes []string `yaml:"expected_event_types"`
+}
+
+// MultiField is an additional mapping of a field's value (e.g. a text
+// variant of a keyword field).
+type MultiField struct {
+	FlatName string `yaml:"flat_name"`
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
 }
 
 func readFields() ([]Field, error) {
@@ -7,19 +7,20 @@ import (
 	"log"
 	"os"
 
-	"github.com/andrewkroh/go-examples/fields-yml-gen/ecs"
 	"github.com/andrewkroh/go-examples/fields-yml/fieldsyml"
 )
 
 // Flags
 var (
-	format string // Output format (list, json).
-	warn   bool   // Warn on invalid ECS field references.
+	format  string // Output format (list, json).
+	warn    bool   // Warn on invalid ECS field references.
+	ecsFile string // Vendored ecs_flat.yml to resolve against.
 )
 
 func init() {
 	flag.StringVar(&format, "f", "list", "Output format (list or json). Defaults to list.")
 	flag.BoolVar(&warn, "w", true, "Warn on invalid external ECS field references.")
+	flag.StringVar(&ecsFile, "ecs", "", "Vendored ecs_flat.yml to resolve ECS references against. Defaults to the embedded ECS version.")
 }
 
 func main() {
@@ -40,10 +41,25 @@ func main() {
 		log.Fatal(err)
 	}
 
-	flat, unresolved := fieldsyml.ResolveECSReferences(flat)
+	var opts fieldsyml.ResolveOptions
+	if ecsFile != "" {
+		if opts.Resolver, err = fieldsyml.NewECSResolverFromFile(ecsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if warn {
+		opts.Warn = func(f fieldsyml.FlatField, msg string) {
+			log.Printf("WARN: %q in %s:%d %s.", f.Name, f.Source, f.SourceLine, msg)
+		}
+	}
+	flat, unresolved := fieldsyml.ResolveECSReferencesWithOptions(flat, opts)
 	if len(unresolved) > 0 && warn {
+		ecsVersion := opts.ECSVersion()
+		if ecsVersion == "" {
+			ecsVersion = "from " + ecsFile
+		}
 		for _, f := range unresolved {
-			log.Printf("WARN: %q in %s:%d does not exist is ECS %v or is not a leaf field.", f.Name, f.Source, f.SourceLine, ecs.Version)
+			log.Printf("WARN: %q in %s:%d does not exist in ECS %v or is not a leaf field.", f.Name, f.Source, f.SourceLine, ecsVersion)
 		}
 	}
 
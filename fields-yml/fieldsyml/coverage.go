@@ -0,0 +1,27 @@
+package fieldsyml
+
+// CoverageReport summarizes how many fields reuse ECS definitions.
+type CoverageReport struct {
+	ECS        int     `json:"ecs"`         // Resolved 'external: ecs' references.
+	Custom     int     `json:"custom"`      // Locally defined fields.
+	Unresolved int     `json:"unresolved"`  // 'external: ecs' references without a definition.
+	ECSPercent float64 `json:"ecs_percent"` // Percentage of all fields that are resolved ECS references.
+}
+
+// ECSCoverage reports the ECS coverage of the resolved and unresolved fields
+// returned by ResolveECSReferences.
+func ECSCoverage(resolved, unresolved []FlatField) CoverageReport {
+	r := CoverageReport{Unresolved: len(unresolved)}
+	for _, f := range resolved {
+		if f.External == "ecs" {
+			r.ECS++
+		} else {
+			r.Custom++
+		}
+	}
+
+	if total := len(resolved) + len(unresolved); total > 0 {
+		r.ECSPercent = 100 * float64(r.ECS) / float64(total)
+	}
+	return r
+}
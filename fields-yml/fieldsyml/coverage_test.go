@@ -0,0 +1,34 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECSCoverage(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs"},
+		{Name: "event.category", External: "ecs"},
+		{Name: "host.name", External: "ecs"},
+		{Name: "not.in.ecs", External: "ecs"},
+		{Name: "aws.cloudtrail.user_identity.type", Type: "keyword"},
+	}
+
+	resolved, unresolved := ResolveECSReferences(flat)
+	report := ECSCoverage(resolved, unresolved)
+
+	assert.Equal(t, CoverageReport{ECS: 3, Custom: 1, Unresolved: 1, ECSPercent: 60}, report)
+	assert.Equal(t, CoverageReport{}, ECSCoverage(nil, nil))
+
+	// An unresolved reference is counted as unresolved even if it declares
+	// its own type.
+	flat[3].Type = "keyword"
+	resolved, unresolved = ResolveECSReferences(flat)
+	require.Len(t, unresolved, 1)
+	assert.Equal(t, report, ECSCoverage(resolved, unresolved))
+}
@@ -1,48 +1,602 @@
 package fieldsyml
 
 import (
+	"strings"
+	"sync"
+	"unicode"
+
 	"github.com/andrewkroh/go-examples/fields-yml-gen/ecs"
 )
 
+// ECSResolver looks up ECS field definitions for a specific ECS version.
+type ECSResolver interface {
+	// Version returns the ECS version used to answer lookups.
+	Version() string
+
+	// GetField returns the field with the given flat name or nil if it does
+	// not exist.
+	GetField(name string) *ecs.Field
+}
+
+// embeddedECS resolves against the ECS version embedded in the ecs package.
+type embeddedECS struct{}
+
+func (embeddedECS) Version() string                 { return ecs.Version }
+func (embeddedECS) GetField(name string) *ecs.Field { return ecs.GetField(name) }
+
+// ECSVersion returns the version of the embedded ECS definitions used when no
+// Resolver is given.
+func ECSVersion() string {
+	return ecs.Version
+}
+
+// MaturityAction controls how references to beta or deprecated ECS fields are
+// handled during resolution.
+type MaturityAction int
+
+const (
+	MaturityKeep MaturityAction = iota // Resolve the reference normally.
+	MaturityWarn                       // Resolve the reference and report a warning.
+	MaturityDrop                       // Omit the field from the result.
+)
+
+// ResolveOptions controls how 'external: ecs' references are resolved.
+type ResolveOptions struct {
+	// Resolver is used to look up ECS fields. It defaults to the embedded
+	// ECS version.
+	Resolver ECSResolver
+
+	// Prefix is prepended to the names of resolved ECS fields. It is used when
+	// a data stream nests its ECS fields under a common key (e.g. "aws.").
+	// References that already carry the prefix are not prefixed twice.
+	Prefix string
+
+	// Concurrency is the number of goroutines used to look up references. A
+	// value less than two looks up references sequentially. The Resolver must
+	// be safe for concurrent use when this is set. The output order is the
+	// same regardless of concurrency.
+	Concurrency int
+
+	// Beta controls the handling of references to beta ECS fields.
+	Beta MaturityAction
+
+	// Deprecated controls the handling of references to deprecated ECS fields.
+	Deprecated MaturityAction
+
+	// Warn is called with the reference and a message for each warning
+	// produced during resolution. Warnings are discarded if it is nil.
+	Warn func(f FlatField, msg string)
+
+	// LeavesOnly drops group and object fields from the output so that only
+	// concrete leaf fields remain. Object fields with an object_type are kept
+	// because they define the mapping of their values.
+	LeavesOnly bool
+
+	// SkipNames lists 'external: ecs' references that are not resolved. They
+	// are returned untouched so that a package can keep its local definition
+	// of a field that also exists in ECS.
+	SkipNames []string
+
+	// CollapseDescriptions replaces runs of whitespace, including line breaks,
+	// in resolved ECS descriptions with a single space.
+	CollapseDescriptions bool
+
+	// MaxDescriptionLength truncates resolved ECS descriptions longer than
+	// this many characters, ending them with "...". Zero disables truncation.
+	MaxDescriptionLength int
+
+	// NormalizeLocalDescriptions applies CollapseDescriptions and
+	// MaxDescriptionLength to the descriptions of local fields too.
+	NormalizeLocalDescriptions bool
+
+	// Rename maps ECS field names to the names under which they are emitted
+	// (e.g. "source.ip" to "src.ip"). It is applied after the lookup, so the
+	// field keeps its ECS definition, and before Prefix. A warning is produced
+	// if a renamed field collides with another field.
+	Rename map[string]string
+
+	// DefaultIgnoreAbove is the ignore_above of keyword fields that do not
+	// declare one, either locally or in ECS. Zero leaves them unset.
+	DefaultIgnoreAbove int
+}
+
+// ECSVersion returns the ECS version that references are resolved against.
+func (o ResolveOptions) ECSVersion() string {
+	return o.resolver().Version()
+}
+
+func (o ResolveOptions) resolver() ECSResolver {
+	if o.Resolver == nil {
+		return embeddedECS{}
+	}
+	return o.Resolver
+}
+
 // ResolveECSReferences resolve 'external: ecs' references to get their type
 // and description. If there are any unresolved references then hasUnresolved
 // will be true (you can iterate the returned values to find 'external: ecs'
 // fields without a type).
+//
+// The resolved fields keep the order of flat. Each reference is replaced in
+// place by the entries it resolves to, in their resolved order, so local
+// fields and references keep their relative positions. Unresolved and dropped
+// references are removed without reordering the remaining fields.
 func ResolveECSReferences(flat []FlatField) (resolved []FlatField, unresolved []FlatField) {
+	return ResolveECSReferencesWithOptions(flat, ResolveOptions{})
+}
+
+// ResolveECSReferencesWithOptions is like ResolveECSReferences but allows
+// the resolution to be customized. A type declared on a reference overrides
+// the ECS type, and a warning is produced unless the override is a compatible
+// narrowing (e.g. keyword to constant_keyword). A flattened field is a leaf,
+// so fields whose names are within a flattened field are dropped with a
+// warning rather than resolved or mapped separately.
+func ResolveECSReferencesWithOptions(flat []FlatField, opts ResolveOptions) (resolved []FlatField, unresolved []FlatField) {
+	resolver := opts.resolver()
+
+	prefix := opts.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	lookups := lookupECSFields(resolver, flat, prefix, opts.Concurrency)
+	flattened := flattenedFields(flat, lookups)
+
+	skip := make(map[string]struct{}, len(opts.SkipNames))
+	for _, name := range opts.SkipNames {
+		skip[name] = struct{}{}
+	}
+
+	var renamed []int // Indexes in out of renamed fields.
 	out := make([]FlatField, 0, len(flat))
-	for _, f := range flat {
-		if f.External != "ecs" {
+	for i, f := range flat {
+		if parent := flattenedAncestor(f.Name, flattened); parent != "" {
+			opts.warn(f, "is within flattened field "+parent+" and is not mapped separately")
+			continue
+		}
+		if _, found := skip[f.Name]; found || f.External != "ecs" {
+			if opts.NormalizeLocalDescriptions {
+				f.Description = opts.normalizeDescription(f.Description)
+			}
 			out = append(out, f)
 			continue
 		}
 
-		fields := lookupECSField(f.Name)
+		fields := lookups[i]
 		if len(fields) == 0 {
 			unresolved = append(unresolved, f)
 			continue
 		}
 
 		for _, ecsField := range fields {
-			ecsField.Source = f.Source
-			ecsField.SourceLine = f.SourceLine
+			if ecsField.Beta && !opts.applyMaturity(opts.Beta, f, "references beta ECS field "+ecsField.Name) {
+				continue
+			}
+			if ecsField.Deprecated != "" && !opts.applyMaturity(opts.Deprecated, f, "references ECS field "+ecsField.Name+" deprecated in "+ecsField.Deprecated) {
+				continue
+			}
+
+			ecsField = opts.mergeECSField(f, ecsField)
+			if name, found := opts.Rename[ecsField.Name]; found {
+				ecsField.Name = name
+				renamed = append(renamed, len(out))
+			}
+			ecsField.Name = prefix + ecsField.Name
+			if ecsField.AliasPath != "" {
+				ecsField.AliasPath = prefix + ecsField.AliasPath
+			}
+			ecsField.ECSVersion = resolver.Version()
 			out = append(out, ecsField)
 		}
 	}
+	opts.validateRenames(out, renamed)
+	opts.applyDefaultIgnoreAbove(out)
+	if opts.LeavesOnly {
+		out = leafFields(out)
+	}
+	opts.validateAliases(out)
 	return out, unresolved
 }
 
-func lookupECSField(name string) []FlatField {
-	if f := ecs.GetField(name); f != nil {
+// mergeECSField applies the settings declared on the reference f to its ECS
+// definition.
+func (o ResolveOptions) mergeECSField(f, ecsField FlatField) FlatField {
+	// A type declared on the reference overrides the ECS type.
+	if f.Type != "" && f.Type != ecsField.Type {
+		if !compatibleTypeOverride(ecsField.Type, f.Type) {
+			o.warn(f, "overrides type of ECS field "+ecsField.Name+" ("+ecsField.Type+") with incompatible type "+f.Type)
+		}
+		ecsField.Type = f.Type
+	}
+
+	// TSDB settings are specific to the data stream so they may be
+	// declared on the reference.
+	if f.Dimension {
+		ecsField.Dimension = true
+	}
+	if f.MetricType != "" {
+		ecsField.MetricType = f.MetricType
+	}
+
+	// Mapping hints declared on the reference take precedence.
+	if f.DocValues != nil {
+		ecsField.DocValues = f.DocValues
+	}
+	if f.Store != nil {
+		ecsField.Store = f.Store
+	}
+	if f.Runtime {
+		ecsField.Runtime = true
+	}
+	if f.IgnoreAbove != nil {
+		ecsField.IgnoreAbove = f.IgnoreAbove
+	}
+
+	ecsField.Description = o.normalizeDescription(ecsField.Description)
+	ecsField.Source = f.Source
+	ecsField.SourceLine = f.SourceLine
+	return ecsField
+}
+
+// validateRenames warns about renamed fields whose new name is also used by
+// another field.
+func (o ResolveOptions) validateRenames(fields []FlatField, renamed []int) {
+	if len(renamed) == 0 {
+		return
+	}
+
+	count := make(map[string]int, len(fields))
+	for _, f := range fields {
+		count[f.Name]++
+	}
+	for _, i := range renamed {
+		if f := fields[i]; count[f.Name] > 1 {
+			o.warn(f, "renamed ECS field "+f.Name+" collides with another field")
+		}
+	}
+}
+
+// normalizeDescription collapses and truncates the description as configured.
+func (o ResolveOptions) normalizeDescription(desc string) string {
+	if o.CollapseDescriptions {
+		desc = strings.Join(strings.Fields(desc), " ")
+	}
+	if o.MaxDescriptionLength > 0 {
+		desc = truncateDescription(desc, o.MaxDescriptionLength)
+	}
+	return desc
+}
+
+// truncateDescription shortens desc to at most max characters, replacing the
+// end with "..." when it is truncated.
+func truncateDescription(desc string, max int) string {
+	const ellipsis = "..."
+
+	runes := []rune(desc)
+	if len(runes) <= max {
+		return desc
+	}
+	if max <= len(ellipsis) {
+		return string(runes[:max])
+	}
+	return strings.TrimRightFunc(string(runes[:max-len(ellipsis)]), unicode.IsSpace) + ellipsis
+}
+
+// applyDefaultIgnoreAbove sets DefaultIgnoreAbove on keyword fields without
+// an ignore_above.
+func (o ResolveOptions) applyDefaultIgnoreAbove(fields []FlatField) {
+	if o.DefaultIgnoreAbove == 0 {
+		return
+	}
+	for i := range fields {
+		if fields[i].Type == "keyword" && fields[i].IgnoreAbove == nil {
+			v := o.DefaultIgnoreAbove
+			fields[i].IgnoreAbove = &v
+		}
+	}
+}
+
+// flattenedFields returns the names of the fields that are, or resolve to,
+// flattened fields.
+func flattenedFields(flat []FlatField, lookups [][]FlatField) map[string]struct{} {
+	names := map[string]struct{}{}
+	for i, f := range flat {
+		typ := f.Type
+		if typ == "" && len(lookups[i]) == 1 {
+			typ = lookups[i][0].Type
+		}
+		if typ == "flattened" {
+			names[f.Name] = struct{}{}
+		}
+	}
+	return names
+}
+
+// flattenedAncestor returns the name of the flattened field that contains
+// name, or an empty string if there is none.
+func flattenedAncestor(name string, flattened map[string]struct{}) string {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+		if _, found := flattened[name[:i]]; found {
+			return name[:i]
+		}
+	}
+	return ""
+}
+
+// leafFields removes group fields and object fields without an object_type.
+func leafFields(fields []FlatField) []FlatField {
+	leaves := fields[:0]
+	for _, f := range fields {
+		switch {
+		case f.Type == "group":
+		case f.Type == "object" && f.ObjectType == "":
+		default:
+			leaves = append(leaves, f)
+		}
+	}
+	return leaves
+}
+
+// validateAliases warns about alias fields whose path is not one of the
+// fields.
+func (o ResolveOptions) validateAliases(fields []FlatField) {
+	names := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		names[f.Name] = struct{}{}
+	}
+	for _, f := range fields {
+		if f.Type != "alias" {
+			continue
+		}
+		if f.AliasPath == "" {
+			o.warn(f, "alias field "+f.Name+" has no path")
+			continue
+		}
+		if _, found := names[f.AliasPath]; !found {
+			o.warn(f, "alias field "+f.Name+" points to "+f.AliasPath+" which does not exist")
+		}
+	}
+}
+
+// applyMaturity applies the action to a reference. It returns false if the
+// field should be dropped.
+func (o ResolveOptions) applyMaturity(action MaturityAction, f FlatField, msg string) bool {
+	switch action {
+	case MaturityWarn:
+		o.warn(f, msg)
+	case MaturityDrop:
+		return false
+	}
+	return true
+}
+
+func (o ResolveOptions) warn(f FlatField, msg string) {
+	if o.Warn != nil {
+		o.Warn(f, msg)
+	}
+}
+
+// typeNarrowings lists the types that may override an ECS type without
+// changing how values are indexed or queried.
+var typeNarrowings = map[string][]string{
+	"keyword":      {"constant_keyword", "wildcard"},
+	"text":         {"match_only_text"},
+	"long":         {"integer", "short", "byte", "unsigned_long"},
+	"double":       {"float", "half_float", "scaled_float"},
+	"float":        {"half_float", "scaled_float"},
+	"scaled_float": {"float", "half_float"},
+	"date":         {"date_nanos"},
+}
+
+// compatibleTypeOverride returns true if the local type is a compatible
+// narrowing of the ECS type.
+func compatibleTypeOverride(ecsType, localType string) bool {
+	for _, t := range typeNarrowings[ecsType] {
+		if t == localType {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupECSFields looks up every 'external: ecs' reference. The result is
+// indexed the same as flat.
+func lookupECSFields(resolver ECSResolver, flat []FlatField, prefix string, concurrency int) [][]FlatField {
+	results := make([][]FlatField, len(flat))
+	lookup := func(i int) {
+		if flat[i].External == "ecs" {
+			results[i] = lookupECSField(resolver, strings.TrimPrefix(flat[i].Name, prefix))
+		}
+	}
+
+	if concurrency < 2 {
+		for i := range flat {
+			lookup(i)
+		}
+		return results
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				lookup(i)
+			}
+		}()
+	}
+	for i := range flat {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+type ecsCacheKey struct {
+	name    string
+	version string
+}
+
+var (
+	ecsCacheMu sync.Mutex
+	ecsCache   = map[ecsCacheKey][]FlatField{}
+)
+
+// ResetECSCache clears the cache of resolved ECS fields.
+func ResetECSCache() {
+	ecsCacheMu.Lock()
+	defer ecsCacheMu.Unlock()
+
+	ecsCache = map[ecsCacheKey][]FlatField{}
+}
+
+// cacheKeyer is implemented by resolvers whose results must be cached
+// separately from other resolvers that report the same Version.
+type cacheKeyer interface {
+	cacheKey() string
+}
+
+// lookupECSField returns the ECS fields for the name. Results are cached per
+// ECS version. Callers receive a copy that they are free to modify.
+func lookupECSField(resolver ECSResolver, name string) []FlatField {
+	key := ecsCacheKey{name: name, version: resolver.Version()}
+	if k, ok := resolver.(cacheKeyer); ok {
+		key.version = k.cacheKey()
+	}
+
+	ecsCacheMu.Lock()
+	fields, found := ecsCache[key]
+	ecsCacheMu.Unlock()
+	if found {
+		return cloneFlatFields(fields)
+	}
+
+	// Resolve without holding the lock so that concurrent lookups of
+	// different names are not serialized.
+	fields = resolveECSField(resolver, name)
+
+	ecsCacheMu.Lock()
+	ecsCache[key] = fields
+	ecsCacheMu.Unlock()
+
+	return cloneFlatFields(fields)
+}
+
+func resolveECSField(resolver ECSResolver, name string) []FlatField {
+	if f := resolver.GetField(name); f != nil {
 		flat := FlatField{
 			Name:        f.FlatName,
 			Type:        f.Type,
 			Description: f.Description,
+			Example:     f.Example,
 			External:    "ecs",
+			Beta:        f.Beta != "",
+			Deprecated:  f.Deprecated,
+			AliasPath:   f.Path,
+
+			ScalingFactor: f.ScalingFactor,
+			Dimension:     f.Dimension,
+			MetricType:    f.MetricType,
+			ObjectType:    f.ObjectType,
+			DocValues:     f.DocValues,
+			IgnoreAbove:   optionalInt(f.IgnoreAbove),
+
+			Format:          f.Format,
+			InputFormat:     f.InputFormat,
+			OutputFormat:    f.OutputFormat,
+			OutputPrecision: f.OutputPrecision,
+		}
+		for _, n := range f.Normalize {
+			if s, ok := n.(string); ok {
+				flat.Normalize = append(flat.Normalize, s)
+			}
+		}
+		for _, v := range f.AllowedValues {
+			flat.AllowedValues = append(flat.AllowedValues, v.Name)
+		}
+		for _, mf := range f.MultiFields {
+			flat.MultiFields = append(flat.MultiFields, MultiField{Name: mf.Name, Type: mf.Type})
 		}
 		return []FlatField{flat}
 	}
 
+	// A child of an object field with dynamic keys (e.g. labels.*) takes
+	// the object's object_type, or keyword if it has none.
+	if parent := objectParent(resolver, name); parent != nil {
+		objectType := parent.ObjectType
+		if objectType == "" {
+			objectType = "keyword"
+		}
+		return []FlatField{{
+			Name:        name,
+			Type:        objectType,
+			Description: parent.Description,
+			External:    "ecs",
+			Beta:        parent.Beta != "",
+			Deprecated:  parent.Deprecated,
+		}}
+	}
+
 	// NOTE: This does not resolve groups of fields anymore.
 	// https://github.com/elastic/elastic-package/pull/818
 	return nil
 }
+
+// objectParent returns the nearest ancestor of name that ECS defines as an
+// object. It returns nil if no ancestor is defined or if the nearest defined
+// ancestor is not an object.
+func objectParent(resolver ECSResolver, name string) *ecs.Field {
+	for i := strings.LastIndexByte(name, '.'); i > 0; i = strings.LastIndexByte(name, '.') {
+		name = name[:i]
+		if f := resolver.GetField(name); f != nil {
+			if f.Type == "object" {
+				return f
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func cloneBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	v := *b
+	return &v
+}
+
+// optionalInt returns a pointer to v, or nil if v is zero (unset in ECS).
+func optionalInt(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+func cloneFlatFields(fields []FlatField) []FlatField {
+	if fields == nil {
+		return nil
+	}
+	out := make([]FlatField, len(fields))
+	for i, f := range fields {
+		f.Normalize = append([]string(nil), f.Normalize...)
+		f.AllowedValues = append([]string(nil), f.AllowedValues...)
+		f.DocValues = cloneBool(f.DocValues)
+		f.Store = cloneBool(f.Store)
+		if f.IgnoreAbove != nil {
+			v := *f.IgnoreAbove
+			f.IgnoreAbove = &v
+		}
+		f.MultiFields = append([]MultiField(nil), f.MultiFields...)
+		out[i] = f
+	}
+	return out
+}
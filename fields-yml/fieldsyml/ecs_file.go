@@ -0,0 +1,60 @@
+package fieldsyml
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/andrewkroh/go-examples/fields-yml-gen/ecs"
+	"gopkg.in/yaml.v3"
+)
+
+// ECSFileResolver resolves against a vendored ecs_flat.yml file, such as one
+// from a specific ECS release.
+type ECSFileResolver struct {
+	digest string // SHA-256 of the file contents.
+	fields map[string]ecs.Field
+}
+
+var _ ECSResolver = (*ECSFileResolver)(nil)
+
+// NewECSResolverFromFile returns a resolver for the ecs_flat.yml at path.
+// ecs_flat.yml does not record its ECS version so Version is empty. Results
+// are cached per file contents rather than per version so that editing the
+// file invalidates them.
+func NewECSResolverFromFile(path string) (*ECSFileResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]ecs.Field
+	if err = yaml.NewDecoder(bytes.NewReader(data)).Decode(&fields); err != nil {
+		return nil, fmt.Errorf("failed decoding ECS fields from %s: %w", path, err)
+	}
+
+	// Don't trust the map key name.
+	sum := sha256.Sum256(data)
+	r := &ECSFileResolver{
+		digest: hex.EncodeToString(sum[:]),
+		fields: make(map[string]ecs.Field, len(fields)),
+	}
+	for _, f := range fields {
+		r.fields[f.FlatName] = f
+	}
+	return r, nil
+}
+
+func (r *ECSFileResolver) Version() string { return "" }
+
+func (r *ECSFileResolver) cacheKey() string { return "file:" + r.digest }
+
+func (r *ECSFileResolver) GetField(name string) *ecs.Field {
+	f, found := r.fields[name]
+	if !found {
+		return nil
+	}
+	return &f
+}
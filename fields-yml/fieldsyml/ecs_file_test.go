@@ -0,0 +1,76 @@
+package fieldsyml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewECSResolverFromFile(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver, err := NewECSResolverFromFile("testdata/vendored/ecs_flat.yml")
+	require.NoError(t, err)
+	assert.Empty(t, resolver.Version())
+	assert.Nil(t, resolver.GetField("host.name"))
+
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs"},
+		{Name: "event.category", External: "ecs"},
+		{Name: "labels.team", External: "ecs"},
+		{Name: "host.name", External: "ecs"},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	require.Len(t, resolved, 3)
+	assert.Equal(t, "wildcard", resolved[0].Type, "vendored type differs from the embedded ECS")
+	assert.Equal(t, []string{"array"}, resolved[1].Normalize)
+	assert.Equal(t, "keyword", resolved[2].Type)
+	assert.Empty(t, resolved[0].ECSVersion)
+
+	// Results are not shared with the embedded ECS through the cache.
+	resolved, _ = ResolveECSReferences(flat[:1])
+	assert.Equal(t, "keyword", resolved[0].Type)
+	assert.Equal(t, ECSVersion(), resolved[0].ECSVersion)
+
+	template, err := ToComponentTemplate("test", []FlatField{{Name: "event.action", Type: "wildcard", External: "ecs"}})
+	require.NoError(t, err)
+	assert.NotContains(t, template["_meta"], "ecs_version")
+
+	// Not present in the vendored schema though it is in the embedded one.
+	require.Len(t, unresolved, 1)
+	assert.Equal(t, "host.name", unresolved[0].Name)
+
+	_, err = NewECSResolverFromFile("testdata/vendored/missing.yml")
+	assert.Error(t, err)
+}
+
+func TestNewECSResolverFromFileEdited(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	path := filepath.Join(t.TempDir(), "ecs_flat.yml")
+	write := func(typ string) {
+		t.Helper()
+		data := "event.action:\n  flat_name: event.action\n  type: " + typ + "\n"
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+	flat := []FlatField{{Name: "event.action", External: "ecs"}}
+
+	write("wildcard")
+	resolver, err := NewECSResolverFromFile(path)
+	require.NoError(t, err)
+	resolved, _ := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	assert.Equal(t, "wildcard", resolved[0].Type)
+
+	// Editing the file must not return the cached fields of the old contents.
+	write("keyword")
+	resolver, err = NewECSResolverFromFile(path)
+	require.NoError(t, err)
+	resolved, _ = ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	assert.Equal(t, "keyword", resolved[0].Type)
+}
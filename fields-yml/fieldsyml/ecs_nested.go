@@ -0,0 +1,69 @@
+package fieldsyml
+
+import "strings"
+
+// ResolveECSReferencesNested is like ResolveECSReferences but it operates on
+// the nested fields.yml structure and keeps the group hierarchy. Resolved
+// references retain their (relative) names. The input is not modified.
+// hasUnresolved is true if any reference could not be resolved; those fields
+// are returned unchanged.
+func ResolveECSReferencesNested(groups []Field) (resolved []Field, hasUnresolved bool) {
+	return ResolveECSReferencesNestedWithOptions(groups, ResolveOptions{})
+}
+
+// ResolveECSReferencesNestedWithOptions is like ResolveECSReferencesNested
+// but allows the resolution to be customized. References are merged with
+// their ECS definition as in ResolveECSReferencesWithOptions. Only the
+// Resolver, Warn and description options apply to nested fields.
+func ResolveECSReferencesNestedWithOptions(groups []Field, opts ResolveOptions) (resolved []Field, hasUnresolved bool) {
+	return opts.resolveNested(nil, groups, &hasUnresolved), hasUnresolved
+}
+
+func (o ResolveOptions) resolveNested(key []string, fields []Field, hasUnresolved *bool) []Field {
+	if fields == nil {
+		return nil
+	}
+
+	out := make([]Field, len(fields))
+	for i, f := range fields {
+		name := append(append([]string(nil), key...), splitName(f.Name)...)
+
+		if len(f.Fields) > 0 {
+			f.Fields = o.resolveNested(name, f.Fields, hasUnresolved)
+			out[i] = f
+			continue
+		}
+
+		if f.External == "ecs" {
+			if ecsFields := lookupECSField(o.resolver(), strings.Join(name, ".")); len(ecsFields) > 0 {
+				ref, _ := flattenField(key, f)
+				f = nestedField(f, o.mergeECSField(ref[0], ecsFields[0]))
+			} else {
+				*hasUnresolved = true
+			}
+		}
+		f.Normalize = append([]string(nil), f.Normalize...)
+		out[i] = f
+	}
+	return out
+}
+
+// nestedField returns the reference f with the definition of the merged flat
+// field. f keeps its relative name.
+func nestedField(f Field, merged FlatField) Field {
+	f.Type = merged.Type
+	f.Description = merged.Description
+	f.Example = merged.Example
+	f.Normalize = merged.Normalize
+	f.Path = merged.AliasPath
+	f.ScalingFactor = merged.ScalingFactor
+	f.Dimension = merged.Dimension
+	f.MetricType = merged.MetricType
+	f.ObjectType = merged.ObjectType
+	f.DocValues = merged.DocValues
+	f.Store = merged.Store
+	f.Runtime = merged.Runtime
+	f.IgnoreAbove = merged.IgnoreAbove
+	f.MultiFields = merged.MultiFields
+	return f
+}
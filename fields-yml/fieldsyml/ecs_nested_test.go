@@ -0,0 +1,124 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/andrewkroh/go-examples/fields-yml-gen/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveECSReferencesNested(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	groups := []Field{
+		{
+			Name: "event",
+			Type: "group",
+			Fields: []Field{
+				{Name: "action", External: "ecs", SourceLine: 4},
+				{Name: "not_in_ecs", External: "ecs"},
+			},
+		},
+		{
+			Name: "aws.cloudtrail",
+			Type: "group",
+			Fields: []Field{
+				{Name: "user_identity.type", Type: "keyword"},
+			},
+		},
+		{Name: "container.cpu.usage", External: "ecs"},
+	}
+
+	resolved, hasUnresolved := ResolveECSReferencesNested(groups)
+	assert.True(t, hasUnresolved)
+	require.Len(t, resolved, 3)
+
+	event := resolved[0]
+	assert.Equal(t, "event", event.Name)
+	require.Len(t, event.Fields, 2)
+	assert.Equal(t, "action", event.Fields[0].Name)
+	assert.Equal(t, "keyword", event.Fields[0].Type)
+	assert.NotEmpty(t, event.Fields[0].Description)
+	assert.Equal(t, 4, event.Fields[0].SourceLine)
+	assert.Equal(t, Field{Name: "not_in_ecs", External: "ecs"}, event.Fields[1])
+
+	assert.Equal(t, groups[1], resolved[1], "local fields are unchanged")
+
+	assert.Equal(t, "container.cpu.usage", resolved[2].Name)
+	assert.Equal(t, "scaled_float", resolved[2].Type)
+	assert.Equal(t, 1000, resolved[2].ScalingFactor)
+
+	// The input is not modified.
+	assert.Empty(t, groups[0].Fields[0].Type)
+
+	_, hasUnresolved = ResolveECSReferencesNested(groups[1:])
+	assert.False(t, hasUnresolved)
+}
+
+func TestResolveECSReferencesNestedMatchesFlat(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	docValues := false
+	ignoreAbove := 256
+	resolver := staticResolver{
+		version: "99.4",
+		fields: map[string]ecs.Field{
+			"event.action":   {FlatName: "event.action", Type: "keyword", Description: "The action\n  captured.", IgnoreAbove: 1024, Normalize: []interface{}{"array"}},
+			"event.duration": {FlatName: "event.duration", Type: "long", MetricType: "gauge"},
+			"host.name":      {FlatName: "host.name", Type: "keyword", MultiFields: []ecs.MultiField{{Name: "text", Type: "match_only_text"}}},
+			"labels":         {FlatName: "labels", Type: "object", ObjectType: "keyword"},
+			"process.pid":    {FlatName: "process.pid", Type: "long", Example: "4242"},
+		},
+	}
+	groups := []Field{
+		{
+			Name: "event",
+			Type: "group",
+			Fields: []Field{
+				{Name: "action", External: "ecs", Type: "long"},
+				{Name: "duration", External: "ecs", MetricType: "counter"},
+			},
+		},
+		{Name: "host.name", External: "ecs", IgnoreAbove: &ignoreAbove, SourceLine: 9},
+		{Name: "labels", External: "ecs"},
+		{
+			Name: "process",
+			Type: "group",
+			Fields: []Field{
+				{Name: "pid", External: "ecs", DocValues: &docValues, Dimension: true},
+			},
+		},
+	}
+
+	var flatWarnings, nestedWarnings []string
+	opts := ResolveOptions{Resolver: resolver, CollapseDescriptions: true}
+
+	flat, err := FlattenFields(groups)
+	require.NoError(t, err)
+	opts.Warn = func(_ FlatField, msg string) { flatWarnings = append(flatWarnings, msg) }
+	want, unresolved := ResolveECSReferencesWithOptions(flat, opts)
+	require.Empty(t, unresolved)
+	for i := range want {
+		want[i].ECSVersion = "" // Not recorded in the nested structure.
+	}
+
+	opts.Warn = func(_ FlatField, msg string) { nestedWarnings = append(nestedWarnings, msg) }
+	nested, hasUnresolved := ResolveECSReferencesNestedWithOptions(groups, opts)
+	require.False(t, hasUnresolved)
+	got, err := FlattenFields(nested)
+	require.NoError(t, err)
+
+	assert.Equal(t, want, got)
+	assert.Equal(t, flatWarnings, nestedWarnings)
+	require.Len(t, nestedWarnings, 1)
+	assert.Contains(t, nestedWarnings[0], "incompatible type long")
+
+	assert.Equal(t, "host.name", nested[1].Name)
+	assert.Equal(t, &ignoreAbove, nested[1].IgnoreAbove)
+	assert.Equal(t, []MultiField{{Name: "text", Type: "match_only_text"}}, nested[1].MultiFields)
+	assert.Equal(t, "keyword", nested[2].ObjectType)
+	assert.Equal(t, &docValues, nested[3].Fields[0].DocValues)
+}
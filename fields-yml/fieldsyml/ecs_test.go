@@ -0,0 +1,625 @@
+package fieldsyml
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/andrewkroh/go-examples/fields-yml-gen/ecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveECSReferencesPrefix(t *testing.T) {
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs", Source: "ecs.yml", SourceLine: 1},
+		{Name: "aws.event.outcome", External: "ecs", Source: "ecs.yml", SourceLine: 3},
+		{Name: "aws.cloudtrail.user_identity.type", Type: "keyword"},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Prefix: "aws."})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 3)
+
+	assert.Equal(t, "aws.event.action", resolved[0].Name)
+	assert.Equal(t, "keyword", resolved[0].Type)
+	assert.NotEmpty(t, resolved[0].Description)
+	assert.Equal(t, "ecs.yml", resolved[0].Source)
+	assert.Equal(t, 1, resolved[0].SourceLine)
+
+	// Already prefixed references are not prefixed again.
+	assert.Equal(t, "aws.event.outcome", resolved[1].Name)
+
+	// Local fields are untouched.
+	assert.Equal(t, flat[2], resolved[2])
+}
+
+// staticResolver is an ECSResolver backed by a fixed set of fields.
+type staticResolver struct {
+	version string
+	fields  map[string]ecs.Field
+}
+
+func (r staticResolver) Version() string { return r.version }
+
+func (r staticResolver) GetField(name string) *ecs.Field {
+	f, found := r.fields[name]
+	if !found {
+		return nil
+	}
+	return &f
+}
+
+func TestResolveECSReferencesCache(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	v99 := staticResolver{
+		version: "99.0",
+		fields: map[string]ecs.Field{
+			"event.action": {FlatName: "event.action", Type: "wildcard", Description: "From 99.0."},
+		},
+	}
+
+	flat := []FlatField{{Name: "event.action", External: "ecs", Source: "a.yml", SourceLine: 7}}
+
+	embedded, unresolved := ResolveECSReferences(flat)
+	require.Empty(t, unresolved)
+	other, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: v99})
+	require.Empty(t, unresolved)
+
+	assert.Equal(t, "keyword", embedded[0].Type)
+	assert.Equal(t, "wildcard", other[0].Type)
+
+	require.Len(t, ecsCache, 2)
+	cached := ecsCache[ecsCacheKey{name: "event.action", version: "99.0"}]
+	require.Len(t, cached, 1)
+	assert.Equal(t, "wildcard", cached[0].Type)
+
+	// The Source applied to the result must not leak into the cache.
+	assert.Equal(t, "a.yml", other[0].Source)
+	assert.Empty(t, cached[0].Source)
+	assert.Zero(t, cached[0].SourceLine)
+
+	ResetECSCache()
+	assert.Empty(t, ecsCache)
+}
+
+func TestResolveECSReferencesConcurrency(t *testing.T) {
+	fields, err := ReadFieldsYAML("testdata/*.yml")
+	require.NoError(t, err)
+	flat, err := FlattenFields(fields)
+	require.NoError(t, err)
+
+	ResetECSCache()
+	sequential, sequentialUnresolved := ResolveECSReferences(flat)
+
+	ResetECSCache()
+	concurrent, concurrentUnresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Concurrency: 4})
+
+	assert.Equal(t, sequential, concurrent)
+	assert.Equal(t, sequentialUnresolved, concurrentUnresolved)
+}
+
+func BenchmarkResolveECSReferences(b *testing.B) {
+	fields, err := ReadFieldsYAML("testdata/*.yml")
+	require.NoError(b, err)
+	flat, err := FlattenFields(fields)
+	require.NoError(b, err)
+
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			opts := ResolveOptions{Concurrency: concurrency}
+			for i := 0; i < b.N; i++ {
+				ResetECSCache()
+				ResolveECSReferencesWithOptions(flat, opts)
+			}
+		})
+	}
+}
+
+func TestResolveECSReferencesMaturity(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver := staticResolver{
+		version: "99.1",
+		fields: map[string]ecs.Field{
+			"event.action":   {FlatName: "event.action", Type: "keyword"},
+			"host.old_field": {FlatName: "host.old_field", Type: "keyword", Deprecated: "8.1"},
+		},
+	}
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs"},
+		{Name: "host.old_field", External: "ecs"},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 2)
+	assert.Equal(t, "8.1", resolved[1].Deprecated)
+
+	resolved, unresolved = ResolveECSReferencesWithOptions(flat, ResolveOptions{
+		Resolver:   resolver,
+		Deprecated: MaturityDrop,
+	})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 1)
+	assert.Equal(t, "event.action", resolved[0].Name)
+
+	var warnings []string
+	resolved, unresolved = ResolveECSReferencesWithOptions(
+		[]FlatField{{Name: "container.cpu.usage", External: "ecs", Source: "ecs.yml", SourceLine: 4}},
+		ResolveOptions{
+			Beta: MaturityWarn,
+			Warn: func(f FlatField, msg string) {
+				warnings = append(warnings, fmt.Sprintf("%s:%d: %s", f.Source, f.SourceLine, msg))
+			},
+		})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 1)
+	assert.True(t, resolved[0].Beta)
+	assert.Equal(t, []string{"ecs.yml:4: references beta ECS field container.cpu.usage"}, warnings)
+}
+
+func TestResolveECSReferencesObjectParent(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver := staticResolver{
+		version: "99.2",
+		fields: map[string]ecs.Field{
+			"dns.answers":  {FlatName: "dns.answers", Type: "object", Description: "Array of DNS answers."},
+			"process.env":  {FlatName: "process.env", Type: "object", ObjectType: "long"},
+			"event.action": {FlatName: "event.action", Type: "keyword"},
+		},
+	}
+	flat := []FlatField{
+		{Name: "dns.answers.name", External: "ecs", Source: "ecs.yml", SourceLine: 2},
+		{Name: "process.env.PATH", External: "ecs"},
+		{Name: "event.action.child", External: "ecs"},
+		{Name: "dns.question.name", External: "ecs"},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	require.Len(t, resolved, 2)
+
+	assert.Equal(t, "dns.answers.name", resolved[0].Name)
+	assert.Equal(t, "keyword", resolved[0].Type)
+	assert.Equal(t, "Array of DNS answers.", resolved[0].Description)
+	assert.Equal(t, "ecs.yml", resolved[0].Source)
+	assert.Equal(t, 2, resolved[0].SourceLine)
+
+	assert.Equal(t, "process.env.PATH", resolved[1].Name)
+	assert.Equal(t, "long", resolved[1].Type)
+
+	// Children of non-object fields and of undefined parents stay unresolved.
+	require.Len(t, unresolved, 2)
+	assert.Equal(t, "event.action.child", unresolved[0].Name)
+	assert.Equal(t, "dns.question.name", unresolved[1].Name)
+}
+
+func TestECSVersion(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	assert.Regexp(t, regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`), ECSVersion())
+	assert.Equal(t, ECSVersion(), ResolveOptions{}.ECSVersion())
+
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs"},
+		{Name: "aws.cloudtrail.user_identity.type", Type: "keyword"},
+	}
+
+	resolved, unresolved := ResolveECSReferences(flat)
+	require.Empty(t, unresolved)
+	assert.Equal(t, ECSVersion(), resolved[0].ECSVersion)
+	assert.Empty(t, resolved[1].ECSVersion, "local fields have no ECS version")
+
+	opts := ResolveOptions{Resolver: staticResolver{
+		version: "99.3",
+		fields:  map[string]ecs.Field{"event.action": {FlatName: "event.action", Type: "keyword"}},
+	}}
+	assert.Equal(t, "99.3", opts.ECSVersion())
+	resolved, unresolved = ResolveECSReferencesWithOptions(flat, opts)
+	require.Empty(t, unresolved)
+	assert.Equal(t, "99.3", resolved[0].ECSVersion)
+}
+
+func TestResolveECSReferencesTypeOverride(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	var warnings []string
+	opts := ResolveOptions{
+		Warn: func(f FlatField, msg string) {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %s", f.Source, f.SourceLine, msg))
+		},
+	}
+
+	flat := []FlatField{
+		{Name: "event.category", External: "ecs", Type: "long", Source: "ecs.yml", SourceLine: 3},
+		{Name: "event.dataset", External: "ecs", Type: "constant_keyword", Source: "ecs.yml", SourceLine: 5},
+		{Name: "event.action", External: "ecs", Type: "keyword", Source: "ecs.yml", SourceLine: 7},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, opts)
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 3)
+
+	assert.Equal(t, "long", resolved[0].Type)
+	assert.Equal(t, "constant_keyword", resolved[1].Type)
+	assert.Equal(t, "keyword", resolved[2].Type)
+	assert.Equal(t, []string{
+		"ecs.yml:3: overrides type of ECS field event.category (keyword) with incompatible type long",
+	}, warnings)
+}
+
+func TestResolveECSReferencesMetricSettings(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	flat := []FlatField{
+		{Name: "container.cpu.usage", External: "ecs", MetricType: "gauge"},
+		{Name: "host.name", External: "ecs", Dimension: true},
+		{Name: "event.action", External: "ecs"},
+	}
+
+	resolved, unresolved := ResolveECSReferences(flat)
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 3)
+
+	assert.Equal(t, "scaled_float", resolved[0].Type)
+	assert.Equal(t, 1000, resolved[0].ScalingFactor)
+	assert.Equal(t, "gauge", resolved[0].MetricType)
+
+	assert.True(t, resolved[1].Dimension)
+	assert.Zero(t, resolved[1].ScalingFactor)
+
+	assert.False(t, resolved[2].Dimension)
+	assert.Empty(t, resolved[2].MetricType)
+
+	// Settings declared in the ECS definition are kept.
+	resolved, _ = ResolveECSReferencesWithOptions(
+		[]FlatField{{Name: "host.id", External: "ecs"}},
+		ResolveOptions{Resolver: staticResolver{
+			version: "99.4",
+			fields:  map[string]ecs.Field{"host.id": {FlatName: "host.id", Type: "keyword", Dimension: true}},
+		}})
+	require.Len(t, resolved, 1)
+	assert.True(t, resolved[0].Dimension)
+}
+
+func TestResolveECSReferencesAlias(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver := staticResolver{
+		version: "99.5",
+		fields: map[string]ecs.Field{
+			"host.hostname":   {FlatName: "host.hostname", Type: "keyword"},
+			"host.name_alias": {FlatName: "host.name_alias", Type: "alias", Path: "host.hostname"},
+			"host.os.alias":   {FlatName: "host.os.alias", Type: "alias", Path: "host.os.name"},
+		},
+	}
+
+	var warnings []string
+	opts := ResolveOptions{
+		Resolver: resolver,
+		Prefix:   "aws",
+		Warn: func(f FlatField, msg string) {
+			warnings = append(warnings, msg)
+		},
+	}
+
+	flat := []FlatField{
+		{Name: "host.hostname", External: "ecs"},
+		{Name: "host.name_alias", External: "ecs"},
+		{Name: "host.os.alias", External: "ecs"},
+		{Name: "aws.local_alias", Type: "alias", AliasPath: "aws.host.hostname"},
+		{Name: "aws.broken_alias", Type: "alias"},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, opts)
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 5)
+	assert.Equal(t, "alias", resolved[1].Type)
+	assert.Equal(t, "aws.host.hostname", resolved[1].AliasPath)
+	assert.Equal(t, "aws.host.os.name", resolved[2].AliasPath)
+
+	assert.Equal(t, []string{
+		"alias field aws.host.os.alias points to aws.host.os.name which does not exist",
+		"alias field aws.broken_alias has no path",
+	}, warnings)
+}
+
+func TestResolveECSReferencesAllowedValues(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "event.outcome", External: "ecs"},
+		{Name: "event.action", External: "ecs"},
+	})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 2)
+	assert.Equal(t, []string{"failure", "success", "unknown"}, resolved[0].AllowedValues)
+	assert.Empty(t, resolved[1].AllowedValues)
+
+	// Callers cannot modify the cached values.
+	resolved[0].AllowedValues[0] = "modified"
+	again, _ := ResolveECSReferences([]FlatField{{Name: "event.outcome", External: "ecs"}})
+	assert.Equal(t, "failure", again[0].AllowedValues[0])
+}
+
+func TestResolveECSReferencesLeavesOnly(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver := staticResolver{
+		version: "99.9",
+		fields: map[string]ecs.Field{
+			"dns.answers":  {FlatName: "dns.answers", Type: "object"},
+			"labels":       {FlatName: "labels", Type: "object", ObjectType: "keyword"},
+			"event.action": {FlatName: "event.action", Type: "keyword"},
+		},
+	}
+	flat := []FlatField{
+		{Name: "aws", Type: "group", Source: "fields.yml", SourceLine: 1},
+		{Name: "aws.tags", Type: "object", Source: "fields.yml", SourceLine: 2},
+		{Name: "aws.region", Type: "keyword", Source: "fields.yml", SourceLine: 3},
+		{Name: "dns.answers", External: "ecs", Source: "ecs.yml", SourceLine: 1},
+		{Name: "labels", External: "ecs", Source: "ecs.yml", SourceLine: 2},
+		{Name: "event.action", External: "ecs", Source: "ecs.yml", SourceLine: 3},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	require.Empty(t, unresolved)
+	assert.Len(t, resolved, 6)
+
+	resolved, unresolved = ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver, LeavesOnly: true})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 3)
+
+	assert.Equal(t, "aws.region", resolved[0].Name)
+	assert.Equal(t, "fields.yml", resolved[0].Source)
+	assert.Equal(t, 3, resolved[0].SourceLine)
+
+	assert.Equal(t, "labels", resolved[1].Name)
+	assert.Equal(t, "keyword", resolved[1].ObjectType)
+	assert.Equal(t, "ecs.yml", resolved[1].Source)
+	assert.Equal(t, 2, resolved[1].SourceLine)
+
+	assert.Equal(t, "event.action", resolved[2].Name)
+	assert.Equal(t, 3, resolved[2].SourceLine)
+}
+
+func TestResolveECSReferencesSkipNames(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs", Type: "constant_keyword", Description: "Local action.", Source: "ecs.yml", SourceLine: 1},
+		{Name: "event.outcome", External: "ecs", Source: "ecs.yml", SourceLine: 2},
+	}
+
+	var warnings []string
+	resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{
+		SkipNames: []string{"event.action"},
+		Warn:      func(f FlatField, msg string) { warnings = append(warnings, msg) },
+	})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 2)
+	assert.Empty(t, warnings)
+
+	// The denylisted field keeps its local definition.
+	assert.Equal(t, flat[0], resolved[0])
+
+	assert.Equal(t, "event.outcome", resolved[1].Name)
+	assert.Equal(t, "keyword", resolved[1].Type)
+	assert.NotEmpty(t, resolved[1].ECSVersion)
+}
+
+func TestResolveECSReferencesDescriptions(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver := staticResolver{
+		version: "99.10",
+		fields: map[string]ecs.Field{
+			"event.category": {
+				FlatName:    "event.category",
+				Type:        "keyword",
+				Description: "This is one of four ECS Categorization Fields.\n\n`event.category` represents\nthe \"big buckets\" of ECS categories.",
+			},
+		},
+	}
+	flat := []FlatField{
+		{Name: "event.category", External: "ecs"},
+		{Name: "aws.region", Type: "keyword", Description: "The AWS\nregion."},
+	}
+
+	resolved, _ := ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver, CollapseDescriptions: true})
+	require.Len(t, resolved, 2)
+	assert.Equal(t, "This is one of four ECS Categorization Fields. `event.category` represents the \"big buckets\" of ECS categories.", resolved[0].Description)
+	assert.Equal(t, "The AWS\nregion.", resolved[1].Description)
+
+	resolved, _ = ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver, CollapseDescriptions: true, MaxDescriptionLength: 30})
+	assert.Equal(t, "This is one of four ECS Cat...", resolved[0].Description)
+	assert.Equal(t, "The AWS\nregion.", resolved[1].Description)
+
+	resolved, _ = ResolveECSReferencesWithOptions(flat, ResolveOptions{
+		Resolver:                   resolver,
+		CollapseDescriptions:       true,
+		MaxDescriptionLength:       10,
+		NormalizeLocalDescriptions: true,
+	})
+	assert.Equal(t, "This is...", resolved[0].Description)
+	assert.Equal(t, "The AWS...", resolved[1].Description)
+
+	// The cached definition is not modified.
+	resolved, _ = ResolveECSReferencesWithOptions(flat, ResolveOptions{Resolver: resolver})
+	assert.Contains(t, resolved[0].Description, "\n\n")
+}
+
+func TestResolveECSReferencesOrder(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolver := staticResolver{
+		version: "99.13",
+		fields: map[string]ecs.Field{
+			"event.action": {FlatName: "event.action", Type: "keyword"},
+			"event.beta":   {FlatName: "event.beta", Type: "keyword", Beta: "beta"},
+			"host.name":    {FlatName: "host.name", Type: "keyword"},
+			"process.env":  {FlatName: "process.env", Type: "object", ObjectType: "keyword"},
+			"source.ip":    {FlatName: "source.ip", Type: "ip"},
+			"user.name":    {FlatName: "user.name", Type: "keyword"},
+		},
+	}
+	flat := []FlatField{
+		{Name: "zeta", Type: "keyword"},
+		{Name: "user.name", External: "ecs"},
+		{Name: "alpha", Type: "long"},
+		{Name: "event.beta", External: "ecs"},
+		{Name: "source.ip", External: "ecs"},
+		{Name: "missing", External: "ecs"},
+		{Name: "middle", Type: "keyword"},
+		{Name: "process.env.PATH", External: "ecs"},
+		{Name: "event.action", External: "ecs"},
+		{Name: "beta", Type: "keyword"},
+		{Name: "host.name", External: "ecs"},
+	}
+	expected := []string{"zeta", "user.name", "alpha", "source.ip", "middle", "process.env.PATH", "event.action", "beta", "host.name"}
+
+	for _, concurrency := range []int{0, 4} {
+		resolved, unresolved := ResolveECSReferencesWithOptions(flat, ResolveOptions{
+			Resolver:    resolver,
+			Beta:        MaturityDrop,
+			Concurrency: concurrency,
+		})
+		require.Len(t, unresolved, 1)
+
+		names := make([]string, 0, len(resolved))
+		for _, f := range resolved {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, expected, names, "concurrency=%d", concurrency)
+	}
+}
+
+func TestResolveECSReferencesRename(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	var warnings []string
+	opts := ResolveOptions{
+		Rename: map[string]string{
+			"source.ip":        "src.ip",
+			"destination.port": "dst.port",
+		},
+		Warn: func(f FlatField, msg string) {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %s", f.Source, f.SourceLine, msg))
+		},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions([]FlatField{
+		{Name: "source.ip", External: "ecs", Source: "ecs.yml", SourceLine: 1},
+		{Name: "destination.port", External: "ecs", Source: "ecs.yml", SourceLine: 2},
+		{Name: "dst.port", Type: "keyword", Source: "fields.yml", SourceLine: 1},
+		{Name: "event.action", External: "ecs", Source: "ecs.yml", SourceLine: 3},
+	}, opts)
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 4)
+
+	assert.Equal(t, "src.ip", resolved[0].Name)
+	assert.Equal(t, "ip", resolved[0].Type)
+	assert.NotEmpty(t, resolved[0].Description)
+	assert.Equal(t, "dst.port", resolved[1].Name)
+	assert.Equal(t, "long", resolved[1].Type)
+	assert.Equal(t, "event.action", resolved[3].Name)
+
+	assert.Equal(t, []string{"ecs.yml:2: renamed ECS field dst.port collides with another field"}, warnings)
+
+	// The rename is applied before the prefix.
+	opts.Prefix = "aws"
+	warnings = nil
+	resolved, _ = ResolveECSReferencesWithOptions([]FlatField{{Name: "source.ip", External: "ecs"}}, opts)
+	assert.Equal(t, "aws.src.ip", resolved[0].Name)
+	assert.Empty(t, warnings)
+}
+
+func TestResolveECSReferencesFlattened(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	var warnings []string
+	opts := ResolveOptions{
+		Warn: func(f FlatField, msg string) {
+			warnings = append(warnings, fmt.Sprintf("%s:%d: %s", f.Source, f.SourceLine, msg))
+		},
+	}
+
+	resolved, unresolved := ResolveECSReferencesWithOptions([]FlatField{
+		{Name: "log.syslog.structured_data", External: "ecs", Source: "ecs.yml", SourceLine: 1},
+		{Name: "log.syslog.structured_data.origin.ip", Type: "ip", Source: "fields.yml", SourceLine: 1},
+		{Name: "labels_blob", Type: "flattened", Source: "fields.yml", SourceLine: 2},
+		{Name: "labels_blob.event.action", External: "ecs", Source: "ecs.yml", SourceLine: 2},
+		{Name: "labels_blobs.id", Type: "keyword", Source: "fields.yml", SourceLine: 3},
+	}, opts)
+	require.Empty(t, unresolved)
+
+	var names []string
+	for _, f := range resolved {
+		names = append(names, f.Name+":"+f.Type)
+	}
+	assert.Equal(t, []string{
+		"log.syslog.structured_data:flattened",
+		"labels_blob:flattened",
+		"labels_blobs.id:keyword",
+	}, names)
+	assert.Equal(t, []string{
+		"fields.yml:1: is within flattened field log.syslog.structured_data and is not mapped separately",
+		"ecs.yml:2: is within flattened field labels_blob and is not mapped separately",
+	}, warnings)
+
+	// Children declared under a flattened field are not expanded.
+	flat, err := FlattenFields([]Field{
+		{Name: "aws.tags", Type: "flattened", Fields: []Field{{Name: "env", Type: "keyword"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, flat, 1)
+	assert.Equal(t, "aws.tags", flat[0].Name)
+	assert.Equal(t, "flattened", flat[0].Type)
+}
+
+func TestResolveECSReferencesDefaultIgnoreAbove(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	ignoreAbove := 64
+	resolved, unresolved := ResolveECSReferencesWithOptions([]FlatField{
+		{Name: "event.action", External: "ecs"},
+		{Name: "message", External: "ecs"},
+		{Name: "my_package.id", Type: "keyword"},
+		{Name: "my_package.code", Type: "keyword", IgnoreAbove: &ignoreAbove},
+		{Name: "my_package.bytes", Type: "long"},
+	}, ResolveOptions{DefaultIgnoreAbove: 256})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 5)
+
+	// ECS defines ignore_above for event.action.
+	require.NotNil(t, resolved[0].IgnoreAbove)
+	assert.Equal(t, 1024, *resolved[0].IgnoreAbove)
+	assert.Nil(t, resolved[1].IgnoreAbove, "message is match_only_text")
+	require.NotNil(t, resolved[2].IgnoreAbove)
+	assert.Equal(t, 256, *resolved[2].IgnoreAbove)
+	require.NotNil(t, resolved[3].IgnoreAbove)
+	assert.Equal(t, 64, *resolved[3].IgnoreAbove)
+	assert.Nil(t, resolved[4].IgnoreAbove)
+
+	mapping, err := ToMapping(resolved[2:3])
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"type": "keyword", "ignore_above": 256},
+		mapping["properties"].(map[string]interface{})["my_package"].(map[string]interface{})["properties"].(map[string]interface{})["id"])
+}
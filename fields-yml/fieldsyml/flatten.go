@@ -23,8 +23,9 @@ func FlattenFields(fields []Field) ([]FlatField, error) {
 }
 
 func flattenField(key []string, f Field) ([]FlatField, error) {
-	// Leaf node.
-	if len(f.Fields) == 0 {
+	// Leaf node. The keys of a flattened field are not mapped so any child
+	// fields declared under it are ignored.
+	if len(f.Fields) == 0 || f.Type == "flattened" {
 		leafName := splitName(f.Name)
 
 		name := make([]string, len(key)+len(leafName))
@@ -37,8 +38,22 @@ func flattenField(key []string, f Field) ([]FlatField, error) {
 				Type:        f.Type,
 				External:    f.External,
 				Description: f.Description,
+				Example:     f.Example,
+				Normalize:   f.Normalize,
+				AliasPath:   f.Path,
 				Source:      f.Source,
 				SourceLine:  f.SourceLine,
+
+				ScalingFactor: f.ScalingFactor,
+				Dimension:     f.Dimension,
+				MetricType:    f.MetricType,
+				ObjectType:    f.ObjectType,
+				DocValues:     f.DocValues,
+				Store:         f.Store,
+				Runtime:       f.Runtime,
+				IgnoreAbove:   f.IgnoreAbove,
+
+				MultiFields: f.MultiFields,
 			},
 		}, nil
 	}
@@ -0,0 +1,31 @@
+package fieldsyml
+
+// ToKibanaFieldFormats returns the Kibana index pattern fieldFormatMap for
+// the fields that have a format (e.g. from resolved ECS references). The map
+// is keyed by field name and each value contains the formatter "id" and, for
+// durations, its "params".
+func ToKibanaFieldFormats(fields []FlatField) map[string]interface{} {
+	formats := map[string]interface{}{}
+	for _, f := range fields {
+		if f.Format == "" {
+			continue
+		}
+
+		format := map[string]interface{}{"id": f.Format}
+		params := map[string]interface{}{}
+		if f.InputFormat != "" {
+			params["inputFormat"] = f.InputFormat
+		}
+		if f.OutputFormat != "" {
+			params["outputFormat"] = f.OutputFormat
+		}
+		if f.OutputPrecision != 0 {
+			params["outputPrecision"] = f.OutputPrecision
+		}
+		if len(params) > 0 {
+			format["params"] = params
+		}
+		formats[f.Name] = format
+	}
+	return formats
+}
@@ -0,0 +1,35 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToKibanaFieldFormats(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "source.bytes", External: "ecs"},
+		{Name: "event.duration", External: "ecs"},
+		{Name: "event.action", External: "ecs"},
+		{Name: "aws.size", Type: "long", Format: "bytes"},
+	})
+	require.Empty(t, unresolved)
+	require.Equal(t, "bytes", resolved[0].Format)
+
+	assert.Equal(t, map[string]interface{}{
+		"source.bytes": map[string]interface{}{"id": "bytes"},
+		"event.duration": map[string]interface{}{
+			"id": "duration",
+			"params": map[string]interface{}{
+				"inputFormat":     "nanoseconds",
+				"outputFormat":    "asMilliseconds",
+				"outputPrecision": 1,
+			},
+		},
+		"aws.size": map[string]interface{}{"id": "bytes"},
+	}, ToKibanaFieldFormats(resolved))
+}
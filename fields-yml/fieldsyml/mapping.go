@@ -0,0 +1,176 @@
+package fieldsyml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToMapping builds the Elasticsearch mapping for the flat fields. The result
+// contains a "properties" object with the fields nested by their dotted
+// names. group fields only create properties, while object and nested fields
+// also declare their type so that, for example, arrays of nested objects are
+// indexed independently. It returns an error if a field that is not a group,
+// object, or nested field has child fields, or if a field is declared twice
+// with different types.
+//
+// Runtime fields are declared under "runtime", keyed by their dotted name,
+// with a placeholder Painless script that must be replaced with one that
+// emits the field's value.
+func ToMapping(fields []FlatField) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	for _, f := range fields {
+		if f.Runtime {
+			runtime, _ := root["runtime"].(map[string]interface{})
+			if runtime == nil {
+				runtime = map[string]interface{}{}
+				root["runtime"] = runtime
+			}
+			runtime[f.Name] = map[string]interface{}{
+				"type":   f.Type,
+				"script": map[string]interface{}{"source": runtimeScriptPlaceholder},
+			}
+			continue
+		}
+
+		node := root
+		parts := strings.Split(f.Name, ".")
+		for i, part := range parts {
+			if typ, ok := node["type"].(string); ok && !hasProperties(typ) {
+				return nil, fmt.Errorf("field %s of type %s cannot have child fields (%s)", strings.Join(parts[:i], "."), typ, f.Name)
+			}
+			props, _ := node["properties"].(map[string]interface{})
+			if props == nil {
+				props = map[string]interface{}{}
+				node["properties"] = props
+			}
+			child, _ := props[part].(map[string]interface{})
+			if child == nil {
+				child = map[string]interface{}{}
+				props[part] = child
+			}
+			node = child
+		}
+
+		if err := setFieldMapping(node, f); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// ToComponentTemplate builds an Elasticsearch component template whose
+// mappings are the ToMapping result for the fields. The name and the ECS
+// version used to resolve the fields are recorded in _meta. The version is
+// that of the first resolved ECS field, or of the embedded ECS definitions
+// if no field references ECS. It is omitted if the ECS fields were resolved
+// against a source without a version (e.g. a vendored ecs_flat.yml).
+func ToComponentTemplate(name string, fields []FlatField) (map[string]interface{}, error) {
+	mapping, err := ToMapping(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mapping for component template %s: %w", name, err)
+	}
+
+	ecsVersion := ECSVersion()
+	for _, f := range fields {
+		if f.External == "ecs" {
+			ecsVersion = f.ECSVersion
+			break
+		}
+	}
+
+	meta := map[string]interface{}{"name": name}
+	if ecsVersion != "" {
+		meta["ecs_version"] = ecsVersion
+	}
+	return map[string]interface{}{
+		"template": map[string]interface{}{
+			"mappings": mapping,
+		},
+		"_meta": meta,
+	}, nil
+}
+
+// runtimeScriptPlaceholder is the script of generated runtime fields.
+const runtimeScriptPlaceholder = "// TODO: emit(value);"
+
+// hasProperties reports whether a field of the type may have child fields.
+func hasProperties(typ string) bool {
+	switch typ {
+	case "group", "object", "nested":
+		return true
+	}
+	return false
+}
+
+func setFieldMapping(node map[string]interface{}, f FlatField) error {
+	if f.Type == "group" {
+		if _, found := node["properties"]; !found {
+			node["properties"] = map[string]interface{}{}
+		}
+		return nil
+	}
+	if f.Type == "" {
+		return nil
+	}
+
+	if typ, ok := node["type"].(string); ok && typ != f.Type {
+		return fmt.Errorf("field %s is declared as both %s and %s", f.Name, typ, f.Type)
+	}
+	if _, found := node["properties"]; found && !hasProperties(f.Type) {
+		return fmt.Errorf("field %s of type %s cannot have child fields", f.Name, f.Type)
+	}
+
+	node["type"] = f.Type
+	switch f.Type {
+	case "alias":
+		node["path"] = f.AliasPath
+	case "scaled_float":
+		if f.ScalingFactor != 0 {
+			node["scaling_factor"] = f.ScalingFactor
+		}
+	}
+	if f.IgnoreAbove != nil {
+		node["ignore_above"] = *f.IgnoreAbove
+	}
+	if f.DocValues != nil {
+		node["doc_values"] = *f.DocValues
+	}
+	if f.Store != nil {
+		node["store"] = *f.Store
+	}
+	if f.Dimension {
+		node["time_series_dimension"] = true
+	}
+	if f.MetricType != "" {
+		node["time_series_metric"] = f.MetricType
+	}
+	if len(f.MultiFields) > 0 {
+		multi := map[string]interface{}{}
+		for _, mf := range f.MultiFields {
+			multi[mf.Name] = map[string]interface{}{"type": mf.Type}
+		}
+		node["fields"] = multi
+	}
+	return nil
+}
+
+// MappingFieldCount returns the number of fields that the mapping of the flat
+// fields would create, as counted against Elasticsearch's
+// index.mapping.total_fields.limit. Each distinct path counts once, including
+// the intermediate objects created by dotted names and the children of nested
+// fields, and each multi-field counts once more.
+func MappingFieldCount(fields []FlatField) int {
+	paths := map[string]struct{}{}
+	for _, f := range fields {
+		for i := 0; i < len(f.Name); i++ {
+			if f.Name[i] == '.' {
+				paths[f.Name[:i]] = struct{}{}
+			}
+		}
+		paths[f.Name] = struct{}{}
+		for _, mf := range f.MultiFields {
+			paths[f.Name+"."+mf.Name] = struct{}{}
+		}
+	}
+	return len(paths)
+}
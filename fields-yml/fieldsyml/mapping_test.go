@@ -0,0 +1,211 @@
+package fieldsyml
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMappingNested(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferencesWithOptions([]FlatField{
+		{Name: "email.attachments", External: "ecs"},
+		{Name: "email.attachments.file.name", External: "ecs"},
+		{Name: "email.attachments.file.size", External: "ecs"},
+		{Name: "email.subject", External: "ecs"},
+	}, ResolveOptions{LeavesOnly: true})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 4)
+	assert.Equal(t, "nested", resolved[0].Type)
+
+	mapping, err := ToMapping(resolved)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"email": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"attachments": map[string]interface{}{
+						"type": "nested",
+						"properties": map[string]interface{}{
+							"file": map[string]interface{}{
+								"properties": map[string]interface{}{
+									"name": map[string]interface{}{"type": "keyword", "ignore_above": 1024},
+									"size": map[string]interface{}{"type": "long"},
+								},
+							},
+						},
+					},
+					"subject": map[string]interface{}{
+						"type":         "keyword",
+						"ignore_above": 1024,
+						"fields": map[string]interface{}{
+							"text": map[string]interface{}{"type": "match_only_text"},
+						},
+					},
+				},
+			},
+		},
+	}, mapping)
+}
+
+func TestToMapping(t *testing.T) {
+	mapping, err := ToMapping([]FlatField{
+		{Name: "aws", Type: "group"},
+		{Name: "aws.cpu", Type: "scaled_float", ScalingFactor: 1000, MetricType: "gauge"},
+		{Name: "aws.region", Type: "keyword", Dimension: true},
+		{Name: "region", Type: "alias", AliasPath: "aws.region"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"aws": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"cpu":    map[string]interface{}{"type": "scaled_float", "scaling_factor": 1000, "time_series_metric": "gauge"},
+					"region": map[string]interface{}{"type": "keyword", "time_series_dimension": true},
+				},
+			},
+			"region": map[string]interface{}{"type": "alias", "path": "aws.region"},
+		},
+	}, mapping)
+
+	_, err = ToMapping([]FlatField{
+		{Name: "aws.region", Type: "keyword"},
+		{Name: "aws.region.name", Type: "keyword"},
+	})
+	assert.ErrorContains(t, err, "aws.region of type keyword cannot have child fields")
+
+	_, err = ToMapping([]FlatField{
+		{Name: "aws.region", Type: "keyword"},
+		{Name: "aws.region", Type: "long"},
+	})
+	assert.ErrorContains(t, err, "declared as both keyword and long")
+}
+
+func TestToMappingDocValuesAndStore(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	store := true
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "event.original", External: "ecs"},
+		{Name: "message", External: "ecs", Store: &store},
+	})
+	require.Empty(t, unresolved)
+	require.NotNil(t, resolved[0].DocValues)
+	assert.False(t, *resolved[0].DocValues)
+	assert.Nil(t, resolved[1].DocValues)
+
+	mapping, err := ToMapping(resolved)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"event": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"original": map[string]interface{}{"type": "keyword", "doc_values": false},
+				},
+			},
+			"message": map[string]interface{}{"type": "match_only_text", "store": true},
+		},
+	}, mapping)
+}
+
+func TestToMappingMultiFields(t *testing.T) {
+	fields := []FlatField{
+		{
+			Name: "user.name",
+			Type: "keyword",
+			MultiFields: []MultiField{
+				{Name: "text", Type: "match_only_text"},
+				{Name: "caseless", Type: "keyword"},
+			},
+		},
+		{Name: "user.id", Type: "keyword"},
+		{Name: "event.outcome", Type: "keyword"},
+	}
+
+	mapping, err := ToMapping(fields)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"type": "keyword",
+		"fields": map[string]interface{}{
+			"text":     map[string]interface{}{"type": "match_only_text"},
+			"caseless": map[string]interface{}{"type": "keyword"},
+		},
+	}, mapping["properties"].(map[string]interface{})["user"].(map[string]interface{})["properties"].(map[string]interface{})["name"])
+
+	// user, user.name, user.name.text, user.name.caseless, user.id, event,
+	// event.outcome.
+	assert.Equal(t, 7, MappingFieldCount(fields))
+}
+
+func TestMappingFieldCountECS(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "email.attachments.file.name", External: "ecs"},
+		{Name: "user.name", External: "ecs"},
+	})
+	require.Empty(t, unresolved)
+	require.Len(t, resolved, 2)
+	assert.Equal(t, []MultiField{{Name: "text", Type: "match_only_text"}}, resolved[1].MultiFields)
+
+	// email, email.attachments, email.attachments.file,
+	// email.attachments.file.name, user, user.name, user.name.text.
+	assert.Equal(t, 7, MappingFieldCount(resolved))
+}
+
+func TestToMappingRuntime(t *testing.T) {
+	mapping, err := ToMapping([]FlatField{
+		{Name: "event.duration", Type: "long"},
+		{Name: "event.duration_ms", Type: "long", Runtime: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"event": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"duration": map[string]interface{}{"type": "long"},
+				},
+			},
+		},
+		"runtime": map[string]interface{}{
+			"event.duration_ms": map[string]interface{}{
+				"type":   "long",
+				"script": map[string]interface{}{"source": runtimeScriptPlaceholder},
+			},
+		},
+	}, mapping)
+}
+
+func TestToComponentTemplate(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "event.action", External: "ecs"},
+		{Name: "my_package.item_usages.id", Type: "keyword"},
+		{Name: "my_package.item_usages.bytes", Type: "long"},
+	})
+	require.Empty(t, unresolved)
+
+	template, err := ToComponentTemplate("logs-my_package.item_usages@package", resolved)
+	require.NoError(t, err)
+	actual, err := json.MarshalIndent(template, "", "  ")
+	require.NoError(t, err)
+
+	expected, err := os.ReadFile("testdata/component-template.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+
+	_, err = ToComponentTemplate("invalid", []FlatField{
+		{Name: "a", Type: "keyword"},
+		{Name: "a.b", Type: "keyword"},
+	})
+	assert.ErrorContains(t, err, "component template invalid")
+}
@@ -0,0 +1,36 @@
+package fieldsyml
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldsMarkdownTable returns a Markdown table of the fields' names, types,
+// and descriptions sorted by name. Pipe characters are escaped and line
+// breaks are replaced with spaces so each field stays on one row.
+func FieldsMarkdownTable(fields []FlatField) string {
+	sorted := make([]FlatField, len(fields))
+	copy(sorted, fields)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var sb strings.Builder
+	sb.WriteString("| Field | Type | Description |\n")
+	sb.WriteString("|---|---|---|\n")
+	for _, f := range sorted {
+		sb.WriteString("| ")
+		sb.WriteString(markdownCell(f.Name))
+		sb.WriteString(" | ")
+		sb.WriteString(markdownCell(f.Type))
+		sb.WriteString(" | ")
+		sb.WriteString(markdownCell(f.Description))
+		sb.WriteString(" |\n")
+	}
+	return sb.String()
+}
+
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,24 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldsMarkdownTable(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "onepassword.uuid", Type: "keyword", Description: "The UUID of the item.\nEither a | or a b."},
+		{Name: "event.kind", External: "ecs"},
+	})
+	require.Empty(t, unresolved)
+
+	table := FieldsMarkdownTable(resolved)
+	assert.Contains(t, table, "| Field | Type | Description |\n|---|---|---|\n| event.kind | keyword | ")
+	assert.Contains(t, table, "\n| onepassword.uuid | keyword | The UUID of the item. Either a \\| or a b. |\n")
+	assert.Equal(t, "| Field | Type | Description |\n|---|---|---|\n", FieldsMarkdownTable(nil))
+}
@@ -0,0 +1,62 @@
+package fieldsyml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Conflict describes two definitions of the same field that disagree.
+type Conflict struct {
+	Name   string
+	First  FlatField // Definition that was kept.
+	Second FlatField // Definition that was discarded.
+	Diffs  []string  // Attributes that differ (e.g. "type: keyword != long").
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s defined in %s:%d and %s:%d with different %s",
+		c.Name, c.First.Source, c.First.SourceLine, c.Second.Source, c.Second.SourceLine, strings.Join(c.Diffs, ", "))
+}
+
+// MergeFields unions the sets of fields by name. When a name is defined more
+// than once the first definition is kept. Later definitions whose type or
+// description differ from the kept one are reported as conflicts. The result
+// is sorted by name.
+func MergeFields(sets ...[]FlatField) ([]FlatField, []Conflict) {
+	var (
+		merged    []FlatField
+		conflicts []Conflict
+		index     = map[string]int{}
+	)
+	for _, set := range sets {
+		for _, f := range set {
+			i, found := index[f.Name]
+			if !found {
+				index[f.Name] = len(merged)
+				merged = append(merged, f)
+				continue
+			}
+
+			if diffs := fieldDiffs(merged[i], f); len(diffs) > 0 {
+				conflicts = append(conflicts, Conflict{Name: f.Name, First: merged[i], Second: f, Diffs: diffs})
+			}
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name < merged[j].Name
+	})
+	return merged, conflicts
+}
+
+func fieldDiffs(a, b FlatField) []string {
+	var diffs []string
+	if a.Type != b.Type {
+		diffs = append(diffs, fmt.Sprintf("type: %s != %s", a.Type, b.Type))
+	}
+	if a.Description != b.Description {
+		diffs = append(diffs, "description")
+	}
+	return diffs
+}
@@ -0,0 +1,34 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeFields(t *testing.T) {
+	base := []FlatField{
+		{Name: "@timestamp", Type: "date", Description: "Event timestamp.", Source: "base-fields.yml", SourceLine: 1},
+		{Name: "data_stream.type", Type: "constant_keyword", Description: "Data stream type.", Source: "base-fields.yml", SourceLine: 4},
+	}
+	custom := []FlatField{
+		{Name: "@timestamp", Type: "date", Description: "Event timestamp.", Source: "fields.yml", SourceLine: 1},
+		{Name: "data_stream.type", Type: "keyword", Description: "Type.", Source: "fields.yml", SourceLine: 7},
+		{Name: "aws.cloudtrail.user_identity.type", Type: "keyword", Source: "fields.yml", SourceLine: 12},
+	}
+
+	merged, conflicts := MergeFields(base, custom)
+	require.Len(t, merged, 3)
+	assert.Equal(t, "@timestamp", merged[0].Name)
+	assert.Equal(t, "aws.cloudtrail.user_identity.type", merged[1].Name)
+	assert.Equal(t, base[1], merged[2], "first definition is kept")
+
+	require.Len(t, conflicts, 1)
+	c := conflicts[0]
+	assert.Equal(t, "data_stream.type", c.Name)
+	assert.Equal(t, base[1], c.First)
+	assert.Equal(t, custom[1], c.Second)
+	assert.Equal(t, []string{"type: constant_keyword != keyword", "description"}, c.Diffs)
+	assert.Equal(t, "data_stream.type defined in base-fields.yml:4 and fields.yml:7 with different type: constant_keyword != keyword, description", c.String())
+}
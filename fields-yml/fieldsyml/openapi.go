@@ -0,0 +1,158 @@
+package fieldsyml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToOpenAPISchema projects the flat fields into an OpenAPI 3 schema of the
+// event. Dotted names become nested object properties, Elasticsearch types
+// are translated to OpenAPI types and formats, and fields normalized to
+// arrays, as well as nested fields, are described as arrays. Alias fields are
+// omitted because they do not appear in the event. It returns an error for
+// types that have no OpenAPI equivalent and for fields that have child fields
+// but are not objects.
+func ToOpenAPISchema(fields []FlatField) (map[string]interface{}, error) {
+	root := map[string]interface{}{"type": "object"}
+	for _, f := range fields {
+		if f.Type == "alias" {
+			continue
+		}
+
+		parent := root
+		parts := strings.Split(f.Name, ".")
+		for i, part := range parts[:len(parts)-1] {
+			child, err := openAPIProperty(parent, part)
+			if err != nil {
+				return nil, fmt.Errorf("field %s cannot have child fields (%s): %w", strings.Join(parts[:i+1], "."), f.Name, err)
+			}
+			parent = child
+		}
+
+		schema, err := openAPIType(f)
+		if err != nil {
+			return nil, err
+		}
+		if f.Description != "" {
+			schema["description"] = f.Description
+		}
+		if err = putOpenAPIProperty(parent, parts[len(parts)-1], schema); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+	}
+	return root, nil
+}
+
+// openAPIProperty returns the object schema of the named property of parent,
+// creating it if needed. The items of an array of objects (nested) are
+// returned.
+func openAPIProperty(parent map[string]interface{}, name string) (map[string]interface{}, error) {
+	props, _ := parent["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+		parent["properties"] = props
+	}
+
+	child, _ := props[name].(map[string]interface{})
+	if child == nil {
+		child = map[string]interface{}{"type": "object"}
+		props[name] = child
+	}
+	if items, ok := child["items"].(map[string]interface{}); ok {
+		child = items
+	}
+	if child["type"] != "object" {
+		return nil, fmt.Errorf("type is %v", child["type"])
+	}
+	return child, nil
+}
+
+// putOpenAPIProperty sets the schema of the named property of parent. An
+// object schema that was created for child fields keeps their properties.
+func putOpenAPIProperty(parent map[string]interface{}, name string, schema map[string]interface{}) error {
+	props, _ := parent["properties"].(map[string]interface{})
+	if props == nil {
+		props = map[string]interface{}{}
+		parent["properties"] = props
+	}
+
+	existing, _ := props[name].(map[string]interface{})
+	if existing != nil {
+		if existing["type"] != "object" {
+			return fmt.Errorf("declared more than once")
+		}
+		target := schema
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			target = items
+		}
+		if target["type"] != "object" {
+			return fmt.Errorf("has child fields but is not an object")
+		}
+		if childProps, ok := existing["properties"]; ok {
+			target["properties"] = childProps
+		}
+	}
+	props[name] = schema
+	return nil
+}
+
+// openAPIType returns the schema of the field's value.
+func openAPIType(f FlatField) (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	switch f.Type {
+	case "group", "flattened":
+		schema = map[string]interface{}{"type": "object"}
+	case "object":
+		schema = map[string]interface{}{"type": "object"}
+		if f.ObjectType != "" {
+			values, err := openAPIType(FlatField{Name: f.Name, Type: f.ObjectType})
+			if err != nil {
+				return nil, err
+			}
+			schema["additionalProperties"] = values
+		}
+	case "nested":
+		return map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "object"},
+		}, nil
+	default:
+		typ, format, ok := openAPITypeOf(f.Type)
+		if !ok {
+			return nil, fmt.Errorf("field %s has type %s which has no OpenAPI equivalent", f.Name, f.Type)
+		}
+		schema = map[string]interface{}{"type": typ}
+		if format != "" {
+			schema["format"] = format
+		}
+	}
+
+	if isNormalizedArray(f) {
+		return map[string]interface{}{"type": "array", "items": schema}, nil
+	}
+	return schema, nil
+}
+
+// openAPITypeOf translates an Elasticsearch leaf type to an OpenAPI type and
+// format.
+func openAPITypeOf(esType string) (typ, format string, ok bool) {
+	switch esType {
+	case "keyword", "constant_keyword", "wildcard", "text", "match_only_text", "ip", "version":
+		return "string", "", true
+	case "date", "date_nanos":
+		return "string", "date-time", true
+	case "long", "unsigned_long":
+		return "integer", "int64", true
+	case "integer", "short", "byte":
+		return "integer", "int32", true
+	case "double", "scaled_float":
+		return "number", "double", true
+	case "float", "half_float":
+		return "number", "float", true
+	case "boolean":
+		return "boolean", "", true
+	case "geo_point":
+		return "object", "", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,50 @@
+package fieldsyml
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToOpenAPISchema(t *testing.T) {
+	schema, err := ToOpenAPISchema([]FlatField{
+		{Name: "@timestamp", Type: "date", Description: "Date/time when the event originated."},
+		{Name: "event.category", Type: "keyword", Normalize: []string{"array"}, Description: "Event category."},
+		{Name: "event.duration", Type: "long"},
+		{Name: "source.ip", Type: "ip"},
+		{Name: "source.geo.location", Type: "geo_point"},
+		{Name: "labels", Type: "object", ObjectType: "keyword"},
+		{Name: "email.attachments", Type: "nested"},
+		{Name: "email.attachments.file.size", Type: "long"},
+		{Name: "my_package.ratio", Type: "scaled_float"},
+		{Name: "my_package.enabled", Type: "boolean"},
+		{Name: "my_package.alias", Type: "alias", AliasPath: "source.ip"},
+	})
+	require.NoError(t, err)
+	actual, err := json.MarshalIndent(schema, "", "  ")
+	require.NoError(t, err)
+
+	expected, err := os.ReadFile("testdata/openapi-schema.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestToOpenAPISchemaErrors(t *testing.T) {
+	_, err := ToOpenAPISchema([]FlatField{{Name: "a", Type: "histogram"}})
+	assert.EqualError(t, err, "field a has type histogram which has no OpenAPI equivalent")
+
+	_, err = ToOpenAPISchema([]FlatField{
+		{Name: "a", Type: "keyword"},
+		{Name: "a.b", Type: "keyword"},
+	})
+	assert.ErrorContains(t, err, "field a cannot have child fields (a.b)")
+
+	_, err = ToOpenAPISchema([]FlatField{
+		{Name: "a.b", Type: "keyword"},
+		{Name: "a", Type: "keyword"},
+	})
+	assert.EqualError(t, err, "field a: has child fields but is not an object")
+}
@@ -1,8 +1,11 @@
 package fieldsyml
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,3 +19,59 @@ func TestParseYAML(t *testing.T) {
 		t.Log(f)
 	}
 }
+
+func TestParseYAMLMetricSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- name: system.cpu
+  type: group
+  fields:
+    - name: pct
+      type: scaled_float
+      scaling_factor: 1000
+      metric_type: gauge
+    - name: core
+      type: keyword
+      dimension: true
+`), 0o644))
+
+	fields, err := ReadFieldsYAML(path)
+	require.NoError(t, err)
+	flat, err := FlattenFields(fields)
+	require.NoError(t, err)
+	require.Len(t, flat, 2)
+
+	assert.Equal(t, "system.cpu.core", flat[0].Name)
+	assert.True(t, flat[0].Dimension)
+	assert.Equal(t, "system.cpu.pct", flat[1].Name)
+	assert.Equal(t, 1000, flat[1].ScalingFactor)
+	assert.Equal(t, "gauge", flat[1].MetricType)
+}
+
+func TestParseYAMLMappingHints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- name: aws.raw
+  type: keyword
+  doc_values: false
+  store: true
+- name: aws.region
+  type: keyword
+  runtime: true
+`), 0o644))
+
+	fields, err := ReadFieldsYAML(path)
+	require.NoError(t, err)
+	flat, err := FlattenFields(fields)
+	require.NoError(t, err)
+	require.Len(t, flat, 2)
+
+	require.NotNil(t, flat[0].DocValues)
+	assert.False(t, *flat[0].DocValues)
+	require.NotNil(t, flat[0].Store)
+	assert.True(t, *flat[0].Store)
+	assert.Nil(t, flat[1].DocValues)
+	assert.Nil(t, flat[1].Store)
+	assert.False(t, flat[0].Runtime)
+	assert.True(t, flat[1].Runtime)
+}
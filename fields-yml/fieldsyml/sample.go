@@ -0,0 +1,114 @@
+package fieldsyml
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// SampleEvent synthesizes an example event from the flat fields. A field's
+// value is its example when one is declared, or otherwise a placeholder based
+// on its type. Fields with allowed values use their example only if it is
+// allowed, and otherwise the first allowed value. Fields normalized to arrays
+// are always written as arrays, so a scalar example becomes a one-element
+// array. group, object, and nested placeholder entries are skipped.
+func SampleEvent(fields []FlatField) map[string]interface{} {
+	event := map[string]interface{}{}
+	for _, f := range fields {
+		switch f.Type {
+		case "group", "object", "nested":
+			continue
+		}
+
+		v := exampleValue(f)
+		if isNormalizedArray(f) {
+			if _, ok := v.([]interface{}); !ok {
+				v = []interface{}{v}
+			}
+		}
+
+		putSampleValue(event, f.Name, v)
+	}
+	return event
+}
+
+func isNormalizedArray(f FlatField) bool {
+	for _, n := range f.Normalize {
+		if n == "array" {
+			return true
+		}
+	}
+	return false
+}
+
+func exampleValue(f FlatField) interface{} {
+	// Prefer a valid value over an example that is not allowed.
+	if len(f.AllowedValues) > 0 && !isAllowedValue(f, f.Example) {
+		return f.AllowedValues[0]
+	}
+	if f.Example == "" {
+		return placeholderValue(f.Type)
+	}
+
+	switch f.Type {
+	case "keyword", "constant_keyword", "wildcard", "text", "match_only_text", "date", "ip":
+		// Only arrays are decoded for string types (e.g. '["a", "b"]').
+		if !strings.HasPrefix(f.Example, "[") {
+			return f.Example
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(f.Example), &v); err != nil {
+		return f.Example
+	}
+	return v
+}
+
+func isAllowedValue(f FlatField, v string) bool {
+	for _, allowed := range f.AllowedValues {
+		if v == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func placeholderValue(typ string) interface{} {
+	switch typ {
+	case "long", "integer", "short", "byte", "unsigned_long":
+		return 1
+	case "float", "double", "half_float", "scaled_float":
+		return 1.5
+	case "boolean":
+		return true
+	case "date":
+		return "2006-01-02T15:04:05.000Z"
+	case "ip":
+		return "192.0.2.1"
+	case "geo_point":
+		return map[string]interface{}{"lat": 0.0, "lon": 0.0}
+	default:
+		return "example"
+	}
+}
+
+// putSampleValue sets the value at the dotted key, creating intermediate
+// objects. Keys whose path is blocked by a non-object value are ignored.
+func putSampleValue(event map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+
+	m := event
+	for _, part := range parts[:len(parts)-1] {
+		v, found := m[part]
+		if !found {
+			v = map[string]interface{}{}
+			m[part] = v
+		}
+		child, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = child
+	}
+	m[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,50 @@
+package fieldsyml
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleEvent(t *testing.T) {
+	flat := []FlatField{
+		{Name: "event.category", External: "ecs"},
+		{Name: "event.action", External: "ecs"},
+		{Name: "onepassword", Type: "group"},
+		{Name: "onepassword.used_version", Type: "long"},
+	}
+	flat, unresolved := ResolveECSReferences(flat)
+	require.Empty(t, unresolved)
+	assert.Equal(t, []string{"array"}, flat[0].Normalize)
+
+	data, err := json.Marshal(SampleEvent(flat))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"event": {
+			"category": ["authentication"],
+			"action": "user-password-change"
+		},
+		"onepassword": {
+			"used_version": 1
+		}
+	}`, string(data))
+}
+
+func TestSampleEventAllowedValues(t *testing.T) {
+	event := SampleEvent([]FlatField{
+		{Name: "event.outcome", Type: "keyword", Example: "success", AllowedValues: []string{"failure", "success"}},
+		{Name: "event.kind", Type: "keyword", Example: "bogus", AllowedValues: []string{"alert", "event"}},
+		{Name: "event.type", Type: "keyword", Normalize: []string{"array"}, AllowedValues: []string{"access", "change"}},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"event": map[string]interface{}{
+			"outcome": "success",
+			"kind":    "alert",
+			"type":    []interface{}{"access"},
+		},
+	}, event)
+}
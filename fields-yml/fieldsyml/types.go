@@ -3,11 +3,25 @@ package fieldsyml
 import "gopkg.in/yaml.v3"
 
 type Field struct {
-	Name        string  `json:"name"`
-	Type        string  `json:"type"`
-	External    string  `json:"external,omitempty"`
-	Fields      []Field `json:"fields,omitempty"`
-	Description string  `json:"description,omitempty"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	External    string   `json:"external,omitempty"`
+	Fields      []Field  `json:"fields,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Example     string   `json:"example,omitempty"`
+	Normalize   []string `json:"normalize,omitempty"`
+	Path        string   `json:"path,omitempty"` // Target of an alias field.
+
+	ScalingFactor int    `json:"scaling_factor,omitempty" yaml:"scaling_factor"`
+	Dimension     bool   `json:"dimension,omitempty"`
+	MetricType    string `json:"metric_type,omitempty" yaml:"metric_type"`
+	ObjectType    string `json:"object_type,omitempty" yaml:"object_type"`
+	DocValues     *bool  `json:"doc_values,omitempty" yaml:"doc_values"`
+	Store         *bool  `json:"store,omitempty"`
+	Runtime       bool   `json:"runtime,omitempty"`
+	IgnoreAbove   *int   `json:"ignore_above,omitempty" yaml:"ignore_above"`
+
+	MultiFields []MultiField `json:"multi_fields,omitempty" yaml:"multi_fields"`
 
 	Source     string `json:"-"` // File from which field was read.
 	SourceLine int    `json:"-"` // Line from which field was read.
@@ -26,11 +40,41 @@ func (f *Field) UnmarshalYAML(value *yaml.Node) error {
 }
 
 type FlatField struct {
-	Name        string `json:"name"`
-	Type        string `json:"type,omitempty"`
-	External    string `json:"external,omitempty"`
-	Description string `json:"description,omitempty"`
+	Name          string   `json:"name"`
+	Type          string   `json:"type,omitempty"`
+	External      string   `json:"external,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	Example       string   `json:"example,omitempty"`
+	Normalize     []string `json:"normalize,omitempty"`      // Normalizations (e.g. "array") expected for values.
+	Beta          bool     `json:"beta,omitempty"`           // ECS field is beta.
+	Deprecated    string   `json:"deprecated,omitempty"`     // ECS version in which the field was deprecated.
+	ECSVersion    string   `json:"ecs_version,omitempty"`    // ECS version used to resolve the reference.
+	AliasPath     string   `json:"path,omitempty"`           // Target of an alias field.
+	AllowedValues []string `json:"allowed_values,omitempty"` // Values expected for the field.
+
+	ScalingFactor int    `json:"scaling_factor,omitempty"` // Scaling factor of a scaled_float.
+	Dimension     bool   `json:"dimension,omitempty"`      // TSDB dimension.
+	MetricType    string `json:"metric_type,omitempty"`    // TSDB metric type (e.g. gauge, counter).
+	ObjectType    string `json:"object_type,omitempty"`    // Type of the values of an object field.
+	DocValues     *bool  `json:"doc_values,omitempty"`     // Mapping doc_values setting, if declared.
+	Store         *bool  `json:"store,omitempty"`          // Mapping store setting, if declared.
+	Runtime       bool   `json:"runtime,omitempty"`        // Mapped as a runtime field rather than indexed.
+	IgnoreAbove   *int   `json:"ignore_above,omitempty"`   // Mapping ignore_above setting of keyword fields, if set.
+
+	MultiFields []MultiField `json:"multi_fields,omitempty"` // Additional mappings of the value (e.g. name.text).
+
+	Format          string `json:"format,omitempty"`           // Kibana field format (e.g. bytes, duration).
+	InputFormat     string `json:"input_format,omitempty"`     // Unit of duration values.
+	OutputFormat    string `json:"output_format,omitempty"`    // Display unit of duration values.
+	OutputPrecision int    `json:"output_precision,omitempty"` // Decimal places of duration values.
 
 	Source     string `json:"-"` // File from which field was read.
 	SourceLine int    `json:"-"` // Line from which field was read.
 }
+
+// MultiField is an additional mapping of a field's value that is indexed
+// under <field>.<name>.
+type MultiField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
@@ -0,0 +1,53 @@
+package fieldsyml
+
+import "fmt"
+
+// TypeChange describes an 'external: ecs' reference whose resolved type
+// differs between two ECS versions.
+type TypeChange struct {
+	Name       string
+	OldType    string
+	NewType    string // Empty if the field is not defined in the new version.
+	Source     string // File containing the reference.
+	SourceLine int    // Line of the reference.
+}
+
+func (c TypeChange) String() string {
+	newType := c.NewType
+	if newType == "" {
+		newType = "undefined"
+	}
+	return fmt.Sprintf("%s:%d: %s changes type from %s to %s", c.Source, c.SourceLine, c.Name, c.OldType, newType)
+}
+
+// ECSTypeChanges resolves the package's fields against both ECS versions and
+// reports the 'external: ecs' references whose type would change when
+// upgrading from one version to the other, in the order of the fields.
+// References that declare their own type are unaffected by an upgrade.
+// References that do not resolve in the old version are ignored.
+func ECSTypeChanges(fields []FlatField, from, to ECSResolver) []TypeChange {
+	oldFields, _ := ResolveECSReferencesWithOptions(fields, ResolveOptions{Resolver: from})
+	newFields, _ := ResolveECSReferencesWithOptions(fields, ResolveOptions{Resolver: to})
+
+	newTypes := make(map[string]string, len(newFields))
+	for _, f := range newFields {
+		if f.External == "ecs" {
+			newTypes[f.Name] = f.Type
+		}
+	}
+
+	var changes []TypeChange
+	for _, f := range oldFields {
+		if f.External != "ecs" || newTypes[f.Name] == f.Type {
+			continue
+		}
+		changes = append(changes, TypeChange{
+			Name:       f.Name,
+			OldType:    f.Type,
+			NewType:    newTypes[f.Name],
+			Source:     f.Source,
+			SourceLine: f.SourceLine,
+		})
+	}
+	return changes
+}
@@ -0,0 +1,46 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/andrewkroh/go-examples/fields-yml-gen/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestECSTypeChanges(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	from := staticResolver{
+		version: "99.11",
+		fields: map[string]ecs.Field{
+			"event.action":   {FlatName: "event.action", Type: "keyword"},
+			"event.original": {FlatName: "event.original", Type: "keyword"},
+			"host.old_field": {FlatName: "host.old_field", Type: "keyword"},
+			"message":        {FlatName: "message", Type: "text"},
+		},
+	}
+	to := staticResolver{
+		version: "99.12",
+		fields: map[string]ecs.Field{
+			"event.action":   {FlatName: "event.action", Type: "keyword"},
+			"event.original": {FlatName: "event.original", Type: "wildcard"},
+			"message":        {FlatName: "message", Type: "match_only_text"},
+		},
+	}
+	flat := []FlatField{
+		{Name: "event.action", External: "ecs", Source: "ecs.yml", SourceLine: 1},
+		{Name: "event.original", External: "ecs", Source: "ecs.yml", SourceLine: 2},
+		{Name: "host.old_field", External: "ecs", Source: "ecs.yml", SourceLine: 3},
+		{Name: "message", External: "ecs", Type: "text", Source: "ecs.yml", SourceLine: 4},
+		{Name: "aws.region", Type: "keyword", Source: "fields.yml", SourceLine: 1},
+	}
+
+	changes := ECSTypeChanges(flat, from, to)
+	assert.Equal(t, []TypeChange{
+		{Name: "event.original", OldType: "keyword", NewType: "wildcard", Source: "ecs.yml", SourceLine: 2},
+		{Name: "host.old_field", OldType: "keyword", Source: "ecs.yml", SourceLine: 3},
+	}, changes)
+	assert.Equal(t, "ecs.yml:2: event.original changes type from keyword to wildcard", changes[0].String())
+	assert.Equal(t, "ecs.yml:3: host.old_field changes type from keyword to undefined", changes[1].String())
+}
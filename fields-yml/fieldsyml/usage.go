@@ -0,0 +1,26 @@
+package fieldsyml
+
+// FilterUsedFields returns the fields whose names are in used (e.g. the
+// fields set by an ingest pipeline) along with the fields that are ancestors
+// of a used name, such as the group or object that contains it. Other fields
+// are dropped so that the generated mapping only covers the fields that are
+// populated. The order of the fields is preserved.
+func FilterUsedFields(fields []FlatField, used []string) []FlatField {
+	keep := make(map[string]struct{}, len(used))
+	for _, name := range used {
+		keep[name] = struct{}{}
+		for i := len(name) - 1; i > 0; i-- {
+			if name[i] == '.' {
+				keep[name[:i]] = struct{}{}
+			}
+		}
+	}
+
+	var out []FlatField
+	for _, f := range fields {
+		if _, found := keep[f.Name]; found {
+			out = append(out, f)
+		}
+	}
+	return out
+}
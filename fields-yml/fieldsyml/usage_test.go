@@ -0,0 +1,31 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterUsedFields(t *testing.T) {
+	ResetECSCache()
+	t.Cleanup(ResetECSCache)
+
+	resolved, unresolved := ResolveECSReferences([]FlatField{
+		{Name: "event", Type: "group"},
+		{Name: "event.action", External: "ecs"},
+		{Name: "event.category", External: "ecs"},
+		{Name: "source.ip", External: "ecs"},
+		{Name: "user.name", External: "ecs"},
+	})
+	require.Empty(t, unresolved)
+
+	used := FilterUsedFields(resolved, []string{"event.action", "user.name", "not.declared"})
+
+	var names []string
+	for _, f := range used {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"event", "event.action", "user.name"}, names)
+	assert.Equal(t, "keyword", used[1].Type)
+}
@@ -0,0 +1,47 @@
+package fieldsyml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ValidateFieldNames checks that each flat field name is a well-formed
+// dotted Elasticsearch field name. Names must not be empty, start or end
+// with a dot, or contain empty segments (e.g. foo..bar). Segments may contain
+// letters, digits, '_', '-', '@', and '*' (for wildcard object keys). An
+// error is returned for each malformed name.
+func ValidateFieldNames(fields []FlatField) []error {
+	var errs []error
+	for _, f := range fields {
+		if reason := invalidFieldName(f.Name); reason != "" {
+			errs = append(errs, fmt.Errorf("%s:%d: invalid field name %q: %s", f.Source, f.SourceLine, f.Name, reason))
+		}
+	}
+	return errs
+}
+
+// invalidFieldName returns the reason the name is malformed, or an empty
+// string if it is valid.
+func invalidFieldName(name string) string {
+	switch {
+	case name == "":
+		return "name is empty"
+	case strings.HasPrefix(name, "."):
+		return "name starts with a dot"
+	case strings.HasSuffix(name, "."):
+		return "name ends with a dot"
+	case strings.Contains(name, ".."):
+		return "name contains an empty segment"
+	}
+
+	for _, r := range name {
+		switch {
+		case r == '.', r == '_', r == '-', r == '@', r == '*':
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+		default:
+			return fmt.Sprintf("name contains invalid character %q", r)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package fieldsyml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFieldNames(t *testing.T) {
+	errs := ValidateFieldNames([]FlatField{
+		{Name: "@timestamp"},
+		{Name: "event.action"},
+		{Name: "aws.tags.*"},
+		{Name: "my-package.item_usages.id"},
+	})
+	assert.Empty(t, errs)
+
+	errs = ValidateFieldNames([]FlatField{
+		{Name: "foo..bar", Source: "fields.yml", SourceLine: 3},
+		{Name: ".leading", Source: "fields.yml", SourceLine: 7},
+		{Name: "trailing.", Source: "fields.yml", SourceLine: 9},
+		{Name: "", Source: "fields.yml", SourceLine: 11},
+		{Name: "has space", Source: "fields.yml", SourceLine: 13},
+		{Name: "event.action"},
+	})
+	if assert.Len(t, errs, 5) {
+		assert.EqualError(t, errs[0], `fields.yml:3: invalid field name "foo..bar": name contains an empty segment`)
+		assert.EqualError(t, errs[1], `fields.yml:7: invalid field name ".leading": name starts with a dot`)
+		assert.EqualError(t, errs[2], `fields.yml:9: invalid field name "trailing.": name ends with a dot`)
+		assert.EqualError(t, errs[3], `fields.yml:11: invalid field name "": name is empty`)
+		assert.EqualError(t, errs[4], `fields.yml:13: invalid field name "has space": name contains invalid character ' '`)
+	}
+}
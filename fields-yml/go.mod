@@ -12,3 +12,5 @@ require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
+
+replace github.com/andrewkroh/go-examples/fields-yml-gen => ../fields-yml-gen
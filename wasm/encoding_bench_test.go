@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// decodeGuest returns a guest whose process decodes the encoded event that
+// the host placed in memory, with its pointer and length at 0 and 4, using
+// the $decode function in decoder. $decode takes a position and returns the
+// position after the decoded value, or -1 if the input is malformed, and
+// counts every decoded value (including map keys) in $count. process stores
+// the count at 8 and returns InvalidArgument unless the whole input was a
+// single value. The input is left in place as the guest's result. reset
+// frees all memory allocated by malloc.
+func decodeGuest(decoder string) string {
+	return testGuest("", `
+  (global $end (mut i32) (i32.const 0))
+  (global $count (mut i32) (i32.const 0))
+  (func $skip (param $p i32) (param $n i32) (result i32)
+    (if (i32.gt_u (local.get $n) (i32.sub (global.get $end) (local.get $p)))
+      (then (return (i32.const -1))))
+    (i32.add (local.get $p) (local.get $n)))
+`+decoder+`
+  (func (export "process") (result i32)
+    (global.set $end (i32.add (i32.load (i32.const 0)) (i32.load (i32.const 4))))
+    (global.set $count (i32.const 0))
+    (if (i32.ne (call $decode (i32.load (i32.const 0))) (global.get $end))
+      (then (return (i32.const 2))))
+    (i32.store (i32.const 8) (global.get $count))
+    (i32.const 0))
+  (func (export "reset")
+    (global.set $heap (i32.const 1024)))
+`)
+}
+
+// jsonDecodeGuest decodes JSON objects, arrays, strings, numbers and literals.
+var jsonDecodeGuest = decodeGuest(`
+  (func $at (param $p i32) (result i32)
+    (if (i32.ge_u (local.get $p) (global.get $end))
+      (then (return (i32.const -1))))
+    (i32.load8_u (local.get $p)))
+  (func $ws (param $p i32) (result i32)
+    (local $c i32)
+    (block $done
+      (loop $next
+        (local.set $c (call $at (local.get $p)))
+        (br_if $done (i32.eqz (i32.or
+          (i32.or (i32.eq (local.get $c) (i32.const 0x20)) (i32.eq (local.get $c) (i32.const 0x09)))
+          (i32.or (i32.eq (local.get $c) (i32.const 0x0a)) (i32.eq (local.get $c) (i32.const 0x0d))))))
+        (local.set $p (i32.add (local.get $p) (i32.const 1)))
+        (br $next)))
+    (local.get $p))
+  (func $literal (param $p i32) (param $word i32) (param $n i32) (result i32)
+    (if (i32.lt_s (call $skip (local.get $p) (i32.const 4)) (i32.const 0))
+      (then (return (i32.const -1))))
+    (if (i32.ne (i32.load (local.get $p)) (local.get $word))
+      (then (return (i32.const -1))))
+    (call $skip (local.get $p) (local.get $n)))
+  (func $value (param $p i32) (result i32)
+    (local $c i32)
+    (local.set $p (call $ws (local.get $p)))
+    (local.set $c (call $at (local.get $p)))
+    (global.set $count (i32.add (global.get $count) (i32.const 1)))
+    ;; string
+    (if (i32.eq (local.get $c) (i32.const 0x22))
+      (then
+        (local.set $p (i32.add (local.get $p) (i32.const 1)))
+        (loop $chars
+          (local.set $c (call $at (local.get $p)))
+          (if (i32.lt_s (local.get $c) (i32.const 0)) (then (return (i32.const -1))))
+          (if (i32.eq (local.get $c) (i32.const 0x22))
+            (then (return (i32.add (local.get $p) (i32.const 1)))))
+          (if (i32.eq (local.get $c) (i32.const 0x5c))
+            (then (local.set $p (i32.add (local.get $p) (i32.const 1)))))
+          (local.set $p (i32.add (local.get $p) (i32.const 1)))
+          (br $chars))))
+    ;; object
+    (if (i32.eq (local.get $c) (i32.const 0x7b))
+      (then
+        (local.set $p (call $ws (i32.add (local.get $p) (i32.const 1))))
+        (if (i32.eq (call $at (local.get $p)) (i32.const 0x7d))
+          (then (return (i32.add (local.get $p) (i32.const 1)))))
+        (loop $members
+          (if (i32.ne (call $at (local.get $p)) (i32.const 0x22)) (then (return (i32.const -1))))
+          (local.set $p (call $value (local.get $p)))
+          (if (i32.lt_s (local.get $p) (i32.const 0)) (then (return (i32.const -1))))
+          (local.set $p (call $ws (local.get $p)))
+          (if (i32.ne (call $at (local.get $p)) (i32.const 0x3a)) (then (return (i32.const -1))))
+          (local.set $p (call $value (i32.add (local.get $p) (i32.const 1))))
+          (if (i32.lt_s (local.get $p) (i32.const 0)) (then (return (i32.const -1))))
+          (local.set $p (call $ws (local.get $p)))
+          (local.set $c (call $at (local.get $p)))
+          (if (i32.eq (local.get $c) (i32.const 0x7d))
+            (then (return (i32.add (local.get $p) (i32.const 1)))))
+          (if (i32.ne (local.get $c) (i32.const 0x2c)) (then (return (i32.const -1))))
+          (local.set $p (call $ws (i32.add (local.get $p) (i32.const 1))))
+          (br $members))))
+    ;; array
+    (if (i32.eq (local.get $c) (i32.const 0x5b))
+      (then
+        (local.set $p (call $ws (i32.add (local.get $p) (i32.const 1))))
+        (if (i32.eq (call $at (local.get $p)) (i32.const 0x5d))
+          (then (return (i32.add (local.get $p) (i32.const 1)))))
+        (loop $elements
+          (local.set $p (call $value (local.get $p)))
+          (if (i32.lt_s (local.get $p) (i32.const 0)) (then (return (i32.const -1))))
+          (local.set $p (call $ws (local.get $p)))
+          (local.set $c (call $at (local.get $p)))
+          (if (i32.eq (local.get $c) (i32.const 0x5d))
+            (then (return (i32.add (local.get $p) (i32.const 1)))))
+          (if (i32.ne (local.get $c) (i32.const 0x2c)) (then (return (i32.const -1))))
+          (local.set $p (i32.add (local.get $p) (i32.const 1)))
+          (br $elements))))
+    ;; literals: "true", "null" and "fals" as little-endian words
+    (if (i32.eq (local.get $c) (i32.const 0x74))
+      (then (return (call $literal (local.get $p) (i32.const 0x65757274) (i32.const 4)))))
+    (if (i32.eq (local.get $c) (i32.const 0x6e))
+      (then (return (call $literal (local.get $p) (i32.const 0x6c6c756e) (i32.const 4)))))
+    (if (i32.eq (local.get $c) (i32.const 0x66))
+      (then
+        (if (i32.ne (call $at (i32.add (local.get $p) (i32.const 4))) (i32.const 0x65))
+          (then (return (i32.const -1))))
+        (return (call $literal (local.get $p) (i32.const 0x736c6166) (i32.const 5)))))
+    ;; number
+    (if (i32.eqz (i32.or (i32.eq (local.get $c) (i32.const 0x2d))
+        (i32.le_u (i32.sub (local.get $c) (i32.const 0x30)) (i32.const 9))))
+      (then (return (i32.const -1))))
+    (block $done
+      (loop $digits
+        (local.set $p (i32.add (local.get $p) (i32.const 1)))
+        (local.set $c (call $at (local.get $p)))
+        (br_if $digits (i32.le_u (i32.sub (local.get $c) (i32.const 0x30)) (i32.const 9)))
+        (br_if $digits (i32.eq (local.get $c) (i32.const 0x2e)))
+        (br_if $digits (i32.eq (local.get $c) (i32.const 0x2b)))
+        (br_if $digits (i32.eq (local.get $c) (i32.const 0x2d)))
+        (br_if $digits (i32.eq (i32.or (local.get $c) (i32.const 0x20)) (i32.const 0x65)))))
+    (local.get $p))
+  (func $decode (param $p i32) (result i32)
+    (local.set $p (call $value (local.get $p)))
+    (if (i32.lt_s (local.get $p) (i32.const 0)) (then (return (i32.const -1))))
+    (call $ws (local.get $p)))
+`)
+
+// msgpackDecodeGuest decodes msgpack maps, arrays, strings, binary, integers,
+// floats, booleans and nil. Extension types are rejected.
+var msgpackDecodeGuest = decodeGuest(`
+  (func $be (param $p i32) (param $width i32) (result i32)
+    (if (i32.eq (local.get $width) (i32.const 1))
+      (then (return (i32.load8_u (local.get $p)))))
+    (if (i32.eq (local.get $width) (i32.const 2))
+      (then (return (i32.or
+        (i32.shl (i32.load8_u (local.get $p)) (i32.const 8))
+        (i32.load8_u offset=1 (local.get $p))))))
+    (i32.or
+      (i32.or
+        (i32.shl (i32.load8_u (local.get $p)) (i32.const 24))
+        (i32.shl (i32.load8_u offset=1 (local.get $p)) (i32.const 16)))
+      (i32.or
+        (i32.shl (i32.load8_u offset=2 (local.get $p)) (i32.const 8))
+        (i32.load8_u offset=3 (local.get $p)))))
+  (func $items (param $p i32) (param $n i32) (result i32)
+    (block $done
+      (loop $next
+        (br_if $done (i32.eqz (local.get $n)))
+        (local.set $p (call $value (local.get $p)))
+        (if (i32.lt_s (local.get $p) (i32.const 0)) (then (return (i32.const -1))))
+        (local.set $n (i32.sub (local.get $n) (i32.const 1)))
+        (br $next)))
+    (local.get $p))
+  ;; $sized decodes a value whose length is in the width bytes at p: a
+  ;; string or binary for kind 0, an array for kind 1 and a map for kind 2.
+  (func $sized (param $p i32) (param $width i32) (param $kind i32) (result i32)
+    (local $n i32)
+    (if (i32.lt_s (call $skip (local.get $p) (local.get $width)) (i32.const 0))
+      (then (return (i32.const -1))))
+    (local.set $n (call $be (local.get $p) (local.get $width)))
+    (local.set $p (i32.add (local.get $p) (local.get $width)))
+    (if (i32.eqz (local.get $kind))
+      (then (return (call $skip (local.get $p) (local.get $n)))))
+    (call $items (local.get $p) (i32.shl (local.get $n) (i32.sub (local.get $kind) (i32.const 1)))))
+  (func $value (param $p i32) (result i32)
+    (local $b i32)
+    (if (i32.ge_u (local.get $p) (global.get $end)) (then (return (i32.const -1))))
+    (global.set $count (i32.add (global.get $count) (i32.const 1)))
+    (local.set $b (i32.load8_u (local.get $p)))
+    (local.set $p (i32.add (local.get $p) (i32.const 1)))
+    ;; positive and negative fixint
+    (if (i32.or (i32.le_u (local.get $b) (i32.const 0x7f)) (i32.ge_u (local.get $b) (i32.const 0xe0)))
+      (then (return (local.get $p))))
+    ;; fixmap, fixarray and fixstr
+    (if (i32.le_u (local.get $b) (i32.const 0x8f))
+      (then (return (call $items (local.get $p) (i32.shl (i32.and (local.get $b) (i32.const 0x0f)) (i32.const 1))))))
+    (if (i32.le_u (local.get $b) (i32.const 0x9f))
+      (then (return (call $items (local.get $p) (i32.and (local.get $b) (i32.const 0x0f))))))
+    (if (i32.le_u (local.get $b) (i32.const 0xbf))
+      (then (return (call $skip (local.get $p) (i32.and (local.get $b) (i32.const 0x1f))))))
+    ;; nil, false and true
+    (if (i32.or (i32.eq (local.get $b) (i32.const 0xc0)) (i32.le_u (i32.sub (local.get $b) (i32.const 0xc2)) (i32.const 1)))
+      (then (return (local.get $p))))
+    ;; bin 8/16/32
+    (if (i32.eq (local.get $b) (i32.const 0xc4)) (then (return (call $sized (local.get $p) (i32.const 1) (i32.const 0)))))
+    (if (i32.eq (local.get $b) (i32.const 0xc5)) (then (return (call $sized (local.get $p) (i32.const 2) (i32.const 0)))))
+    (if (i32.eq (local.get $b) (i32.const 0xc6)) (then (return (call $sized (local.get $p) (i32.const 4) (i32.const 0)))))
+    ;; float 32/64, uint 8/16/32/64 and int 8/16/32/64
+    (if (i32.eq (local.get $b) (i32.const 0xca)) (then (return (call $skip (local.get $p) (i32.const 4)))))
+    (if (i32.eq (local.get $b) (i32.const 0xcb)) (then (return (call $skip (local.get $p) (i32.const 8)))))
+    (if (i32.le_u (i32.sub (local.get $b) (i32.const 0xcc)) (i32.const 7))
+      (then (return (call $skip (local.get $p)
+        (i32.shl (i32.const 1) (i32.and (i32.sub (local.get $b) (i32.const 0xcc)) (i32.const 3)))))))
+    ;; str 8/16/32, array 16/32 and map 16/32
+    (if (i32.eq (local.get $b) (i32.const 0xd9)) (then (return (call $sized (local.get $p) (i32.const 1) (i32.const 0)))))
+    (if (i32.eq (local.get $b) (i32.const 0xda)) (then (return (call $sized (local.get $p) (i32.const 2) (i32.const 0)))))
+    (if (i32.eq (local.get $b) (i32.const 0xdb)) (then (return (call $sized (local.get $p) (i32.const 4) (i32.const 0)))))
+    (if (i32.eq (local.get $b) (i32.const 0xdc)) (then (return (call $sized (local.get $p) (i32.const 2) (i32.const 1)))))
+    (if (i32.eq (local.get $b) (i32.const 0xdd)) (then (return (call $sized (local.get $p) (i32.const 4) (i32.const 1)))))
+    (if (i32.eq (local.get $b) (i32.const 0xde)) (then (return (call $sized (local.get $p) (i32.const 2) (i32.const 2)))))
+    (if (i32.eq (local.get $b) (i32.const 0xdf)) (then (return (call $sized (local.get $p) (i32.const 4) (i32.const 2)))))
+    (i32.const -1))
+  (func $decode (param $p i32) (result i32)
+    (call $value (local.get $p)))
+`)
+
+var benchEvent = map[string]interface{}{
+	"@timestamp": "2022-03-03T21:25:12.198Z",
+	"message":    sampleMessage,
+	"event": map[string]interface{}{
+		"action":   "reveal",
+		"category": []interface{}{"file"},
+		"kind":     "event",
+		"duration": float64(1234),
+	},
+	"source": map[string]interface{}{
+		"ip":   "10.10.10.10",
+		"port": float64(443),
+	},
+}
+
+var encodings = []struct {
+	name      string
+	guest     string
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+}{
+	{"json", jsonDecodeGuest, json.Marshal, json.Unmarshal},
+	{"msgpack", msgpackDecodeGuest, msgpack.Marshal, msgpack.Unmarshal},
+}
+
+// roundTrip encodes event, copies it into guest memory, has the guest decode
+// it with process, and decodes the guest's result. It returns the decoded
+// event and the number of values the guest decoded.
+func roundTrip(t testing.TB, wm *wasmModule, marshal func(interface{}) ([]byte, error), unmarshal func([]byte, interface{}) error, event map[string]interface{}) (map[string]interface{}, uint32) {
+	t.Helper()
+
+	_, err := wm.CallExport("reset")
+	require.NoError(t, err)
+
+	data, err := marshal(event)
+	require.NoError(t, err)
+	ptr, length := writeGuestString(t, wm, string(data))
+	require.NoError(t, wm.putUint32(0, uint32(ptr)))
+	require.NoError(t, wm.putUint32(4, uint32(length)))
+
+	rtn, err := wm.process()
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+
+	count, err := wm.guestBytes(8, 4)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, unmarshal([]byte(readGuestResult(t, wm, 0, 4)), &decoded))
+	return decoded, binary.LittleEndian.Uint32(count)
+}
+
+func TestDecodeGuests(t *testing.T) {
+	// 1 root, 4 keys, 2 strings, event and source with 4 and 2 keys
+	// and values, and one category.
+	const values = 1 + 4 + 2 + (1 + 8 + 1) + (1 + 4)
+
+	for _, enc := range encodings {
+		t.Run(enc.name, func(t *testing.T) {
+			wm := newTestModule(t, enc.guest)
+
+			decoded, count := roundTrip(t, wm, enc.marshal, enc.unmarshal, benchEvent)
+			assert.EqualValues(t, values, count)
+			assert.Equal(t, len(benchEvent), len(decoded))
+			assert.Equal(t, benchEvent["message"], decoded["message"])
+
+			data, err := enc.marshal(benchEvent)
+			require.NoError(t, err)
+			for _, malformed := range [][]byte{data[:len(data)-1], append(data[:len(data):len(data)], data[0])} {
+				_, err = wm.CallExport("reset")
+				require.NoError(t, err)
+				ptr, length := writeGuestString(t, wm, string(malformed))
+				require.NoError(t, wm.putUint32(0, uint32(ptr)))
+				require.NoError(t, wm.putUint32(4, uint32(length)))
+
+				rtn, err := wm.process()
+				require.NoError(t, err)
+				assert.Equal(t, int32(StatusInvalidArgument), rtn)
+			}
+		})
+	}
+}
+
+// BenchmarkEventEncoding compares JSON and msgpack for exchanging an event
+// with the guest. The host benchmark only encodes and decodes the event on
+// the host. The round-trip benchmark also copies the encoded event into guest
+// memory and has the guest decode it with process before the host decodes
+// the result.
+func BenchmarkEventEncoding(b *testing.B) {
+	for _, enc := range encodings {
+		b.Run(enc.name, func(b *testing.B) {
+			data, err := enc.marshal(benchEvent)
+			require.NoError(b, err)
+
+			b.Run("host", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					data, err := enc.marshal(benchEvent)
+					if err != nil {
+						b.Fatal(err)
+					}
+					var decoded map[string]interface{}
+					if err = enc.unmarshal(data, &decoded); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(data)), "bytes")
+			})
+
+			b.Run("roundtrip", func(b *testing.B) {
+				wm := newTestModule(b, enc.guest)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					roundTrip(b, wm, enc.marshal, enc.unmarshal, benchEvent)
+				}
+				b.ReportMetric(float64(len(data)), "bytes")
+			})
+		})
+	}
+}
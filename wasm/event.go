@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// FieldType identifies the JSON kind of an event value. It is written into
+// guest memory by elastic_get_field_v2.
+type FieldType int32
+
+const (
+	FieldTypeNull FieldType = iota
+	FieldTypeBool
+	FieldTypeNumber
+	FieldTypeString
+	FieldTypeArray
+	FieldTypeObject
+)
+
+// fieldTypeOf returns the FieldType of a value decoded by encoding/json.
+func fieldTypeOf(v interface{}) FieldType {
+	switch v.(type) {
+	case bool:
+		return FieldTypeBool
+	case float64, int, int32, int64, uint, uint32, uint64:
+		return FieldTypeNumber
+	case string:
+		return FieldTypeString
+	case []interface{}:
+		return FieldTypeArray
+	case map[string]interface{}:
+		return FieldTypeObject
+	default:
+		return FieldTypeNull
+	}
+}
+
+// getValue returns the value at the dotted key (e.g. "event.action").
+func getValue(event map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+
+	m := event
+	for i, part := range parts {
+		v, found := m[part]
+		if !found {
+			return nil, false
+		}
+		if i == len(parts)-1 {
+			return v, true
+		}
+		if m, found = v.(map[string]interface{}); !found {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// putValue sets the value at the dotted key, creating intermediate objects as
+// needed. Non-object values in the path are replaced.
+func putValue(event map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+
+	m := event
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := m[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			m[part] = child
+		}
+		m = child
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// deleteValue removes the value at the dotted key. It returns false if the key
+// does not exist.
+func deleteValue(event map[string]interface{}, key string) bool {
+	parts := strings.Split(key, ".")
+
+	m := event
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := m[part].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		m = child
+	}
+
+	last := parts[len(parts)-1]
+	if _, found := m[last]; !found {
+		return false
+	}
+	delete(m, last)
+	return true
+}
+
+// fieldKeys returns the sorted dotted keys of every value in the event that
+// is not an object. Empty objects are included.
+func fieldKeys(event map[string]interface{}) []string {
+	var keys []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+				walk(prefix+k+".", child)
+				continue
+			}
+			keys = append(keys, prefix+k)
+		}
+	}
+	walk("", event)
+	sort.Strings(keys)
+	return keys
+}
+
+// splitMetadata separates the @metadata object from a document. The returned
+// event contains all other top-level keys. The document is not modified.
+func splitMetadata(doc map[string]interface{}) (event, metadata map[string]interface{}) {
+	event = make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		if k == "@metadata" {
+			if m, ok := v.(map[string]interface{}); ok {
+				metadata = m
+				continue
+			}
+		}
+		event[k] = v
+	}
+	return event, metadata
+}
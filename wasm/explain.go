@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// explainModule compiles the module and writes a table of its imports and
+// exports to w. The module is not instantiated so this works for guests whose
+// imports the runner does not provide.
+func explainModule(w io.Writer, wasmData []byte) error {
+	store := wasmer.NewStore(wasmer.NewEngine())
+	module, err := wasmer.NewModule(store, wasmData)
+	if err != nil {
+		return fmt.Errorf("failed to compile module: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMPORT\tNAMESPACE\tKIND\tSIGNATURE")
+	for _, imp := range module.Imports() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", imp.Name(), imp.Module(), imp.Type().Kind(), signature(imp.Type()))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "EXPORT\tKIND\tSIGNATURE")
+	for _, exp := range module.Exports() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", exp.Name(), exp.Type().Kind(), signature(exp.Type()))
+	}
+	return tw.Flush()
+}
+
+// signature returns the signature of a function (e.g. "(i32, i32) -> (i32)")
+// or an empty string for other kinds of externs.
+func signature(t *wasmer.ExternType) string {
+	fn := t.IntoFunctionType()
+	if fn == nil {
+		return ""
+	}
+	return "(" + valueTypes(fn.Params()) + ") -> (" + valueTypes(fn.Results()) + ")"
+}
+
+func valueTypes(types []*wasmer.ValueType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.Kind().String()
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+func TestExplainModule(t *testing.T) {
+	wasmBytes, err := wasmer.Wat2Wasm(getEventGuest)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, explainModule(&buf, wasmBytes))
+	out := buf.String()
+	t.Log(out)
+
+	assert.Regexp(t, `elastic_get_event\s+elastic\s+func\s+\(i32, i32\) -> \(i32\)`, out)
+	assert.Regexp(t, `malloc\s+func\s+\(i32\) -> \(i32\)`, out)
+	assert.Regexp(t, `process\s+func\s+\(\) -> \(i32\)`, out)
+	assert.Regexp(t, `memory\s+memory`, out)
+
+	assert.Error(t, explainModule(&buf, []byte("not wasm")))
+}
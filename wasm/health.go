@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// HealthCheck runs process() over a canary event and returns an error unless
+// the guest returns StatusOK before ctx is done. It is intended for readiness
+// probes, which bound the check by giving ctx a deadline. The guest's memory,
+// event, and metadata are restored afterwards so the check does not affect
+// the next process() call, and a failure to restore is returned. Like
+// processContext, cancellation is only observed by guests that poll
+// elastic_should_cancel, but a check that overruns fails even if the guest
+// returns StatusOK.
+func (m *wasmModule) HealthCheck(ctx context.Context) (err error) {
+	event, metadata, snapshot := m.event, m.metadata, m.snapshot
+	m.snapshot = nil
+	m.Snapshot()
+	defer func() {
+		if restoreErr := m.Restore(); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to restore guest memory after health check: %w", restoreErr))
+		}
+		m.event, m.metadata, m.snapshot = event, metadata, snapshot
+	}()
+
+	m.event = map[string]interface{}{"message": sampleMessage}
+	m.metadata = nil
+
+	rtn, err := m.processContext(ctx)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("health check did not complete: %w", err)
+	}
+	if Status(rtn) != StatusOK {
+		return fmt.Errorf("health check failed: process() returned status %d", rtn)
+	}
+	return nil
+}
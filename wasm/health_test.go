@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingGuest's process always returns StatusInternalFailure.
+var failingGuest = testGuest("", `
+  (func (export "process") (result i32)
+    (i32.const 1))
+`)
+
+func TestHealthCheck(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		wm := newTestModule(t, statefulGuest)
+		event := map[string]interface{}{"message": "original"}
+		wm.SetEvent(event)
+
+		require.NoError(t, wm.HealthCheck(context.Background()))
+
+		// The check leaves no state behind.
+		assert.Equal(t, event, wm.Event())
+		assert.Nil(t, wm.snapshot)
+		rtn, err := wm.process()
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), rtn)
+	})
+
+	t.Run("failing", func(t *testing.T) {
+		wm := newTestModule(t, failingGuest)
+		assert.EqualError(t, wm.HealthCheck(context.Background()), "health check failed: process() returned status 1")
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		wm := newTestModule(t, addGuest)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, wm.HealthCheck(ctx), context.Canceled)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		wm := newTestModule(t, addGuest)
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+		assert.ErrorIs(t, wm.HealthCheck(ctx), context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+
+	"github.com/dustin/go-humanize"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// uses64BitPointers reports whether the guest was compiled for memory64
+// (wasm64). wasmer does not expose the index type of a memory so this is
+// detected from the guest's malloc export, which takes a pointer-sized length.
+func uses64BitPointers(module *wasmer.Module) bool {
+	for _, export := range module.Exports() {
+		if export.Name() != "malloc" {
+			continue
+		}
+		if fn := export.Type().IntoFunctionType(); fn != nil {
+			params := fn.Params()
+			return len(params) == 1 && params[0].Kind() == wasmer.I64
+		}
+	}
+	return false
+}
+
+// ptrKind returns the value type of guest pointers and lengths.
+func (m *wasmModule) ptrKind() wasmer.ValueKind {
+	if m.ptr64 {
+		return wasmer.I64
+	}
+	return wasmer.I32
+}
+
+// ptrArg returns the guest pointer or length passed as a host call argument.
+func (m *wasmModule) ptrArg(v wasmer.Value) int64 {
+	if m.ptr64 {
+		return v.I64()
+	}
+	return int64(uint32(v.I32()))
+}
+
+// guestBytes returns the slice of guest memory at [ptr, ptr+length). It
+// returns an error rather than panicking when the range is out of bounds.
+func (m *wasmModule) guestBytes(ptr, length int64) ([]byte, error) {
+	data := m.memory.Data()
+	size := int64(len(data))
+	if ptr < 0 || length < 0 || ptr > size || length > size-ptr {
+		return nil, fmt.Errorf("guest memory access out of bounds (ptr=%d, len=%d, size=%d)", ptr, length, len(data))
+	}
+	return data[ptr : ptr+length], nil
+}
+
+// putUint32 writes v as a little-endian uint32 at the guest pointer.
+func (m *wasmModule) putUint32(ptr int64, v uint32) error {
+	b, err := m.guestBytes(ptr, 4)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(b, v)
+	return nil
+}
+
+// putUint64 writes v as a little-endian uint64 at the guest pointer.
+func (m *wasmModule) putUint64(ptr int64, v uint64) error {
+	b, err := m.guestBytes(ptr, 8)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(b, v)
+	return nil
+}
+
+// putPtr writes a guest pointer or length at the guest pointer using the
+// guest's pointer width.
+func (m *wasmModule) putPtr(ptr int64, v int64) error {
+	if m.ptr64 {
+		return m.putUint64(ptr, uint64(v))
+	}
+	return m.putUint32(ptr, uint32(v))
+}
+
+// writeGuestBuffer copies data into newly allocated guest memory and writes
+// the resulting pointer and length to the guest's out-pointers.
+func (m *wasmModule) writeGuestBuffer(data []byte, rtnPtr, rtnLen int64) error {
+	if !m.ptr64 && len(data) > math.MaxInt32 {
+		return fmt.Errorf("buffer of %d bytes is too large for guest memory", len(data))
+	}
+	size := int64(len(data))
+
+	ptr, err := m.malloc(size)
+	if err != nil {
+		return err
+	}
+
+	// Get the buffer after malloc because memory may have grown.
+	buf, err := m.guestBytes(ptr, size)
+	if err != nil {
+		return err
+	}
+	copy(buf, data)
+
+	if err = m.putPtr(rtnPtr, ptr); err != nil {
+		return err
+	}
+	return m.putPtr(rtnLen, size)
+}
+
+// Snapshot captures a copy of the guest's memory. Call it after
+// instantiation, before the first process() call, and then call Restore
+// before each process() call so that state written to memory by one run is
+// not visible to the next. Guest globals are not captured.
+func (m *wasmModule) Snapshot() {
+	m.snapshot = append(m.snapshot[:0], m.memory.Data()...)
+}
+
+// Restore writes the memory captured by Snapshot back to the guest. Memory
+// cannot shrink so if it grew since the snapshot the additional pages are
+// zeroed.
+func (m *wasmModule) Restore() error {
+	if m.snapshot == nil {
+		return errors.New("no memory snapshot to restore")
+	}
+
+	data := m.memory.Data()
+	if len(data) < len(m.snapshot) {
+		return fmt.Errorf("guest memory (%d bytes) is smaller than the snapshot (%d bytes)", len(data), len(m.snapshot))
+	}
+	n := copy(data, m.snapshot)
+	for i := range data[n:] {
+		data[n+i] = 0
+	}
+	return nil
+}
+
+// WriteMemory writes the guest's entire linear memory to w and returns the
+// number of bytes written.
+func (m *wasmModule) WriteMemory(w io.Writer) (int64, error) {
+	return io.Copy(w, bytes.NewReader(m.memory.Data()))
+}
+
+// writeMemoryFile writes the guest's linear memory to the file at path.
+func writeMemoryFile(m *wasmModule, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := m.WriteMemory(f)
+	if err != nil {
+		return err
+	}
+	log.Printf("Wrote %v of guest memory to %s", humanize.Bytes(uint64(n)), path)
+	return f.Close()
+}
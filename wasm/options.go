@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log/slog"
+	"math/rand"
+
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// Option configures a wasmModule.
+type Option func(*wasmModule)
+
+// WithReadOnlyEvent prevents the guest from modifying the event.
+//
+// When strict is false, elastic_put_field and elastic_delete_field are still
+// provided to the guest but every call is rejected with StatusInvalidArgument
+// and the event is left unchanged. When strict is true, they are not provided
+// at all so instantiating a guest that imports them fails.
+func WithReadOnlyEvent(strict bool) Option {
+	return func(m *wasmModule) {
+		m.readOnly = true
+		m.readOnlyStrict = strict
+	}
+}
+
+// WithLogger sets the logger used for structured guest logs written with
+// elastic_log_kv. It defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *wasmModule) {
+		m.logger = logger
+	}
+}
+
+// WithAllocationLimit caps the memory that the host allocates in the guest
+// (e.g. to return field values) during each process() call. maxBytes limits
+// the total size and maxCalls limits the number of allocations. Zero disables
+// a limit. When a limit is exceeded the host call, and every allocating host
+// call after it, fails with StatusInternalFailure and process() returns an
+// error.
+func WithAllocationLimit(maxBytes int64, maxCalls int) Option {
+	return func(m *wasmModule) {
+		m.maxAllocBytes = maxBytes
+		m.maxAllocCalls = maxCalls
+	}
+}
+
+// WithAllocationTrace logs, through the module's logger, each allocation
+// the host makes in guest memory with its size and pointer, and after each
+// process() call the number and total size of the allocations made during
+// it. Use it to find leaks of host-allocated buffers that the guest does not
+// free.
+func WithAllocationTrace() Option {
+	return func(m *wasmModule) {
+		m.traceAlloc = true
+	}
+}
+
+// WithRandSeed seeds the source of the numbers returned by elastic_random
+// so that guests that use randomness (e.g. for sampling) are reproducible.
+// Without it the source is seeded from the current time.
+func WithRandSeed(seed int64) Option {
+	return func(m *wasmModule) {
+		m.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithSizeLimits caps the size of the data exchanged with the guest.
+// maxEventBytes limits the serialized event returned by elastic_get_event,
+// which fails with StatusInternalFailure when it is exceeded. maxValueBytes
+// limits the encoded value passed to elastic_put_field, which is rejected
+// with StatusInvalidArgument when it is exceeded. Zero disables a limit.
+func WithSizeLimits(maxEventBytes, maxValueBytes int64) Option {
+	return func(m *wasmModule) {
+		m.maxEventBytes = maxEventBytes
+		m.maxValueBytes = maxValueBytes
+	}
+}
+
+// HostCallObserver is called after each host function call made by the
+// guest with the function name (e.g. elastic_get_field), its arguments, and
+// the Status it returned to the guest. Host calls that trap are reported
+// with StatusInternalFailure.
+type HostCallObserver func(name string, args []wasmer.Value, status Status)
+
+// WithHostCallObserver registers an observer of the guest's host calls. It is
+// intended for tests that assert on the host interactions of a guest. Without
+// an observer the host functions are not wrapped.
+func WithHostCallObserver(observer HostCallObserver) Option {
+	return func(m *wasmModule) {
+		m.onHostCall = observer
+	}
+}
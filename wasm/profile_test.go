@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+func TestProfiling(t *testing.T) {
+	defer func(module, input string) {
+		modulePath, inputPath = module, input
+	}(modulePath, inputPath)
+
+	dir := t.TempDir()
+	wasmBytes, err := wasmer.Wat2Wasm(addGuest)
+	require.NoError(t, err)
+	modulePath = filepath.Join(dir, "add.wasm")
+	require.NoError(t, os.WriteFile(modulePath, wasmBytes, 0o644))
+	inputPath = "testdata/event.json"
+
+	cpuPath := filepath.Join(dir, "cpu.pprof")
+	memPath := filepath.Join(dir, "mem.pprof")
+	stop, err := startProfiling(cpuPath, memPath)
+	require.NoError(t, err)
+	_, err = run()
+	require.NoError(t, err)
+	require.NoError(t, stop())
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.NotZero(t, info.Size(), path)
+	}
+}
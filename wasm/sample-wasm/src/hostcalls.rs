@@ -43,6 +43,128 @@ pub fn get_field(field: &str) -> Result<Option<String>, Status> {
     }
 }
 
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_get_field_v2(
+        addr: *const u8,
+        size: usize,
+        return_buffer_data: *mut *mut u8,
+        return_buffer_size: *mut usize,
+        return_type: *mut FieldType,
+    ) -> Status;
+}
+
+/// Like get_field but also returns the JSON type of the value.
+pub fn get_field_with_type(field: &str) -> Result<Option<(String, FieldType)>, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    let mut return_type: FieldType = FieldType::Null;
+    unsafe {
+        match elastic_get_field_v2(
+            field.as_ptr(),
+            field.len(),
+            &mut return_data,
+            &mut return_size,
+            &mut return_type,
+        ) {
+            Status::Ok => {
+                if !return_data.is_null() {
+                    // This vector will now own the return data memory and deallocate it.
+                    let field_value = String::from_utf8(Vec::from_raw_parts(
+                        return_data,
+                        return_size,
+                        return_size,
+                    ))
+                    .unwrap();
+
+                    Ok(Some((field_value, return_type)))
+                } else {
+                    Ok(None)
+                }
+            }
+            Status::NotFound => Ok(None),
+            status => panic!("unexpected status: {}", status as i32),
+        }
+    }
+}
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_get_metadata(
+        addr: *const u8,
+        size: usize,
+        return_buffer_data: *mut *mut u8,
+        return_buffer_size: *mut usize,
+    ) -> Status;
+}
+
+/// Returns the raw JSON value of a key from the event's @metadata.
+pub fn get_metadata(key: &str) -> Result<Option<String>, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    unsafe {
+        match elastic_get_metadata(key.as_ptr(), key.len(), &mut return_data, &mut return_size) {
+            Status::Ok => {
+                if !return_data.is_null() {
+                    // This vector will now own the return data memory and deallocate it.
+                    let value = String::from_utf8(Vec::from_raw_parts(
+                        return_data,
+                        return_size,
+                        return_size,
+                    ))
+                    .unwrap();
+
+                    Ok(Some(value))
+                } else {
+                    Ok(None)
+                }
+            }
+            Status::NotFound => Ok(None),
+            status => panic!("unexpected status: {}", status as i32),
+        }
+    }
+}
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_get_event(return_buffer_data: *mut *mut u8, return_buffer_size: *mut usize) -> Status;
+}
+
+/// Returns the whole event encoded as JSON.
+pub fn get_event() -> Result<String, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    unsafe {
+        match elastic_get_event(&mut return_data, &mut return_size) {
+            Status::Ok => {
+                // This vector will now own the return data memory and deallocate it.
+                Ok(String::from_utf8(Vec::from_raw_parts(return_data, return_size, return_size)).unwrap())
+            }
+            status => Err(status),
+        }
+    }
+}
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_list_fields(return_buffer_data: *mut *mut u8, return_buffer_size: *mut usize) -> Status;
+}
+
+/// Returns the dotted names of all fields in the event as a JSON array.
+pub fn list_fields() -> Result<String, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    unsafe {
+        match elastic_list_fields(&mut return_data, &mut return_size) {
+            Status::Ok => {
+                // This vector will now own the return data memory and deallocate it.
+                Ok(String::from_utf8(Vec::from_raw_parts(return_data, return_size, return_size)).unwrap())
+            }
+            status => Err(status),
+        }
+    }
+}
+
 #[link(wasm_import_module = "elastic")]
 extern "C" {
     fn elastic_put_field(
@@ -62,6 +184,22 @@ pub fn put_field(key: &str, value: &str) -> Result<(), Status> {
     }
 }
 
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_delete_field(key_addr: *const u8, key_size: usize) -> Status;
+}
+
+/// Deletes a key from the event. Returns false if the key did not exist.
+pub fn delete_field(key: &str) -> Result<bool, Status> {
+    unsafe {
+        match elastic_delete_field(key.as_ptr(), key.len()) {
+            Status::Ok => Ok(true),
+            Status::NotFound => Ok(false),
+            status => Err(status),
+        }
+    }
+}
+
 #[link(wasm_import_module = "elastic")]
 extern "C" {
     fn elastic_log(level: i32, message_data: *const u8, message_size: usize) -> Status;
@@ -76,6 +214,27 @@ pub fn log(level: LogLevel, message: &str) {
     }
 }
 
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_log_kv(
+        level: i32,
+        message_data: *const u8,
+        message_size: usize,
+        fields_data: *const u8,
+        fields_size: usize,
+    ) -> Status;
+}
+
+/// Logs a message with structured fields. fields must be a JSON object.
+pub fn log_kv(level: LogLevel, message: &str, fields: &str) -> Result<(), Status> {
+    unsafe {
+        match elastic_log_kv(level as i32, message.as_ptr(), message.len(), fields.as_ptr(), fields.len()) {
+            Status::Ok => Ok(()),
+            status => Err(status),
+        }
+    }
+}
+
 #[link(wasm_import_module = "elastic")]
 extern "C" {
     fn elastic_get_current_time_nanoseconds(return_time: *mut u64) -> Status;
@@ -90,3 +249,100 @@ pub fn get_current_time() -> Result<SystemTime, Status> {
         }
     }
 }
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_should_cancel() -> i32;
+}
+
+/// Returns true if the host has requested that processing stop. Poll it in
+/// long running loops and return early when it is set.
+pub fn should_cancel() -> bool {
+    unsafe { elastic_should_cancel() != 0 }
+}
+
+/// Takes ownership of a buffer allocated by the host for a return value.
+unsafe fn return_buffer(data: *mut u8, size: usize) -> Vec<u8> {
+    // This vector will now own the return data memory and deallocate it.
+    Vec::from_raw_parts(data, size, size)
+}
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_hash(
+        algorithm: i32,
+        data: *const u8,
+        size: usize,
+        return_buffer_data: *mut *mut u8,
+        return_buffer_size: *mut usize,
+    ) -> Status;
+}
+
+/// Returns the hex encoded digest of data.
+pub fn hash(algorithm: HashAlgorithm, data: &[u8]) -> Result<String, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    unsafe {
+        match elastic_hash(algorithm as i32, data.as_ptr(), data.len(), &mut return_data, &mut return_size) {
+            Status::Ok => Ok(String::from_utf8(return_buffer(return_data, return_size)).unwrap()),
+            status => Err(status),
+        }
+    }
+}
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_base64_encode(
+        data: *const u8,
+        size: usize,
+        return_buffer_data: *mut *mut u8,
+        return_buffer_size: *mut usize,
+    ) -> Status;
+    fn elastic_base64_decode(
+        data: *const u8,
+        size: usize,
+        return_buffer_data: *mut *mut u8,
+        return_buffer_size: *mut usize,
+    ) -> Status;
+}
+
+/// Returns the standard base64 encoding of data.
+pub fn base64_encode(data: &[u8]) -> Result<String, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    unsafe {
+        match elastic_base64_encode(data.as_ptr(), data.len(), &mut return_data, &mut return_size) {
+            Status::Ok => Ok(String::from_utf8(return_buffer(return_data, return_size)).unwrap()),
+            status => Err(status),
+        }
+    }
+}
+
+/// Decodes standard base64. Invalid input returns Status::InvalidArgument.
+pub fn base64_decode(encoded: &str) -> Result<Vec<u8>, Status> {
+    let mut return_data: *mut u8 = null_mut();
+    let mut return_size: usize = 0;
+    unsafe {
+        match elastic_base64_decode(encoded.as_ptr(), encoded.len(), &mut return_data, &mut return_size) {
+            Status::Ok => Ok(return_buffer(return_data, return_size)),
+            status => Err(status),
+        }
+    }
+}
+
+#[link(wasm_import_module = "elastic")]
+extern "C" {
+    fn elastic_random(return_value: *mut u64) -> Status;
+}
+
+/// Returns a random number from the host. The host's -seed flag makes the
+/// sequence reproducible.
+pub fn random() -> Result<u64, Status> {
+    let mut return_value: u64 = 0;
+    unsafe {
+        match elastic_random(&mut return_value) {
+            Status::Ok => Ok(return_value),
+            status => Err(status),
+        }
+    }
+}
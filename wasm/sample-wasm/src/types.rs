@@ -16,3 +16,22 @@ pub enum Status {
     InvalidArgument = 2,
     NotFound = 3,
 }
+
+#[repr(i32)]
+#[derive(Debug, PartialEq)]
+pub enum FieldType {
+    Null = 0,
+    Bool = 1,
+    Number = 2,
+    String = 3,
+    Array = 4,
+    Object = 5,
+}
+
+#[repr(i32)]
+#[derive(Debug)]
+pub enum HashAlgorithm {
+    Md5 = 0,
+    Sha1 = 1,
+    Sha256 = 2,
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+func TestReadModuleFromStdin(t *testing.T) {
+	wasmBytes, err := wasmer.Wat2Wasm(addGuest)
+	require.NoError(t, err)
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	go func() {
+		defer w.Close()
+		w.Write(wasmBytes)
+	}()
+
+	data, err := readInput(stdinPath, r)
+	require.NoError(t, err)
+	assert.Equal(t, wasmBytes, data)
+
+	wm, err := newWasmModule(data)
+	require.NoError(t, err)
+	rtn, err := wm.CallExport("add", int32(1), int32(2))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), rtn)
+}
+
+func TestValidateFlags(t *testing.T) {
+	defer func(module, input string, w bool) {
+		modulePath, inputPath, watch = module, input, w
+	}(modulePath, inputPath, watch)
+
+	modulePath, inputPath, watch = stdinPath, "testdata/event.json", false
+	assert.NoError(t, validateFlags())
+
+	inputPath = stdinPath
+	assert.ErrorContains(t, validateFlags(), "cannot both be read from stdin")
+
+	inputPath, watch = "", true
+	assert.ErrorContains(t, validateFlags(), "-watch cannot be used")
+}
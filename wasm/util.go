@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// HashAlgorithm selects the digest computed by elastic_hash.
+type HashAlgorithm int32
+
+const (
+	HashMD5 HashAlgorithm = iota
+	HashSHA1
+	HashSHA256
+)
+
+func (a HashAlgorithm) new() hash.Hash {
+	switch a {
+	case HashMD5:
+		return md5.New()
+	case HashSHA1:
+		return sha1.New()
+	case HashSHA256:
+		return sha256.New()
+	default:
+		return nil
+	}
+}
+
+// hash writes the hex encoded digest of the guest data into newly allocated
+// guest memory. Its arguments are the algorithm, the data pointer and length,
+// and the return pointer and length.
+func (m *wasmModule) hash(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 5 {
+		return nil, fmt.Errorf("hash requires 5 arguments, but got %d", len(args))
+	}
+
+	h := HashAlgorithm(args[0].I32()).new()
+	if h == nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+	data, err := m.guestBytes(m.ptrArg(args[1]), m.ptrArg(args[2]))
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+	h.Write(data)
+
+	return m.writeUtilResult([]byte(hex.EncodeToString(h.Sum(nil))), args[3], args[4])
+}
+
+// base64Encode writes the standard base64 encoding of the guest data into
+// newly allocated guest memory.
+func (m *wasmModule) base64Encode(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("base64_encode requires 4 arguments, but got %d", len(args))
+	}
+
+	data, err := m.guestBytes(m.ptrArg(args[0]), m.ptrArg(args[1]))
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(out, data)
+	return m.writeUtilResult(out, args[2], args[3])
+}
+
+// base64Decode is the inverse of base64Encode. Invalid base64 input returns
+// StatusInvalidArgument.
+func (m *wasmModule) base64Decode(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("base64_decode requires 4 arguments, but got %d", len(args))
+	}
+
+	data, err := m.guestBytes(m.ptrArg(args[0]), m.ptrArg(args[1]))
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+
+	out := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(out, data)
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+	return m.writeUtilResult(out[:n], args[2], args[3])
+}
+
+// random writes a random uint64 from the module's seedable source to the
+// guest out-pointer.
+func (m *wasmModule) random(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("random requires 1 argument, but got %d", len(args))
+	}
+
+	if err := m.putUint64(m.ptrArg(args[0]), m.rand.Uint64()); err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
+}
+
+// writeUtilResult writes the result of a utility host function to the guest
+// and returns its status.
+func (m *wasmModule) writeUtilResult(data []byte, rtnPtr, rtnLen wasmer.Value) ([]wasmer.Value, error) {
+	if err := m.writeGuestBuffer(data, m.ptrArg(rtnPtr), m.ptrArg(rtnLen)); err != nil {
+		if errors.Is(err, errAllocationLimit) {
+			return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, nil
+		}
+		return nil, err
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
+}
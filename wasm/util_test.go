@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// utilGuest exports wrappers for the utility host functions that write the
+// result's pointer and length at 0 and 4 and return the host call's status.
+var utilGuest = testGuest(`
+  (import "elastic" "elastic_hash" (func $hash (param i32 i32 i32 i32 i32) (result i32)))
+  (import "elastic" "elastic_base64_encode" (func $base64_encode (param i32 i32 i32 i32) (result i32)))
+  (import "elastic" "elastic_base64_decode" (func $base64_decode (param i32 i32 i32 i32) (result i32)))
+  (import "elastic" "elastic_random" (func $random (param i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "hash") (param $alg i32) (param $ptr i32) (param $len i32) (result i32)
+    (call $hash (local.get $alg) (local.get $ptr) (local.get $len) (i32.const 0) (i32.const 4)))
+  (func (export "base64_encode") (param $ptr i32) (param $len i32) (result i32)
+    (call $base64_encode (local.get $ptr) (local.get $len) (i32.const 0) (i32.const 4)))
+  (func (export "base64_decode") (param $ptr i32) (param $len i32) (result i32)
+    (call $base64_decode (local.get $ptr) (local.get $len) (i32.const 0) (i32.const 4)))
+  (func (export "random") (result i32)
+    (call $random (i32.const 8)))
+`)
+
+func TestHash(t *testing.T) {
+	wm := newTestModule(t, utilGuest)
+	ptr, length := writeGuestString(t, wm, "hello world")
+
+	testCases := map[HashAlgorithm]string{
+		HashMD5:    "5eb63bbbe01eeed093cb22bb8f5acdc3",
+		HashSHA1:   "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+		HashSHA256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+	}
+	for alg, expected := range testCases {
+		rtn, err := wm.CallExport("hash", int32(alg), ptr, length)
+		require.NoError(t, err)
+		require.Equal(t, int32(StatusOK), rtn)
+		assert.Equal(t, expected, readGuestResult(t, wm, 0, 4))
+	}
+
+	rtn, err := wm.CallExport("hash", int32(99), ptr, length)
+	require.NoError(t, err)
+	assert.Equal(t, int32(StatusInvalidArgument), rtn)
+}
+
+func TestBase64(t *testing.T) {
+	wm := newTestModule(t, utilGuest)
+	input := "hello\x00world\xff"
+	ptr, length := writeGuestString(t, wm, input)
+
+	rtn, err := wm.CallExport("base64_encode", ptr, length)
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+	encoded := readGuestResult(t, wm, 0, 4)
+	assert.Equal(t, "aGVsbG8Ad29ybGT/", encoded)
+
+	ptr, length = writeGuestString(t, wm, encoded)
+	rtn, err = wm.CallExport("base64_decode", ptr, length)
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, input, readGuestResult(t, wm, 0, 4))
+
+	ptr, length = writeGuestString(t, wm, "not base64!")
+	rtn, err = wm.CallExport("base64_decode", ptr, length)
+	require.NoError(t, err)
+	assert.Equal(t, int32(StatusInvalidArgument), rtn)
+}
+
+func TestRandomSeed(t *testing.T) {
+	sequence := func(seed int64) []uint64 {
+		wm := newTestModule(t, utilGuest, WithRandSeed(seed))
+
+		var values []uint64
+		for i := 0; i < 3; i++ {
+			rtn, err := wm.CallExport("random")
+			require.NoError(t, err)
+			require.Equal(t, int32(StatusOK), rtn)
+
+			b, err := wm.guestBytes(8, 8)
+			require.NoError(t, err)
+			values = append(values, binary.LittleEndian.Uint64(b))
+		}
+		return values
+	}
+
+	first := sequence(42)
+	assert.Equal(t, first, sequence(42))
+	assert.NotEqual(t, first, sequence(43))
+}
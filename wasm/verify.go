@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// stdinPath is the path used on the command line to read from stdin.
+const stdinPath = "-"
+
+// readInput reads the whole file at path, or stdin if path is "-".
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == stdinPath {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+// readEvent reads a JSON object from a file, or from stdin if path is "-".
+func readEvent(path string) (map[string]interface{}, error) {
+	data, err := readInput(path, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	var event map[string]interface{}
+	if err = json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed decoding event from %q: %w", path, err)
+	}
+	return event, nil
+}
+
+// reportVerification compares the event to the expected event at
+// expectedPath, writes any differences to w, and reports whether the events
+// match.
+func reportVerification(w io.Writer, event map[string]interface{}, expectedPath string) (matched bool, err error) {
+	diffs, err := verifyEvent(event, expectedPath)
+	if err != nil {
+		return false, err
+	}
+	if len(diffs) == 0 {
+		log.Println("Event matches", expectedPath)
+		return true, nil
+	}
+	fmt.Fprintf(w, "Event does not match %s:\n", expectedPath)
+	for _, d := range diffs {
+		fmt.Fprintln(w, d)
+	}
+	return false, nil
+}
+
+// verifyEvent compares the event to the expected event contained in the file
+// at expectedPath. It returns the differences, if any.
+func verifyEvent(event map[string]interface{}, expectedPath string) ([]string, error) {
+	expected, err := readEvent(expectedPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize the event to the same types that JSON decoding produces.
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var actual map[string]interface{}
+	if err = json.Unmarshal(data, &actual); err != nil {
+		return nil, err
+	}
+
+	return diffEvents(expected, actual), nil
+}
+
+// diffEvents returns a sorted list of differences between two events. Objects
+// are compared key by key so key order does not matter. Each difference is
+// prefixed by "-" (missing), "+" (unexpected), or "~" (changed).
+func diffEvents(expected, actual map[string]interface{}) []string {
+	expectedFlat := map[string]interface{}{}
+	flattenEvent("", expected, expectedFlat)
+	actualFlat := map[string]interface{}{}
+	flattenEvent("", actual, actualFlat)
+
+	var diffs []string
+	for k, want := range expectedFlat {
+		got, found := actualFlat[k]
+		switch {
+		case !found:
+			diffs = append(diffs, fmt.Sprintf("- %s: %s", k, toJSON(want)))
+		case !reflect.DeepEqual(want, got):
+			diffs = append(diffs, fmt.Sprintf("~ %s: expected %s, got %s", k, toJSON(want), toJSON(got)))
+		}
+	}
+	for k, got := range actualFlat {
+		if _, found := expectedFlat[k]; !found {
+			diffs = append(diffs, fmt.Sprintf("+ %s: %s", k, toJSON(got)))
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i][2:] < diffs[j][2:]
+	})
+	return diffs
+}
+
+// flattenEvent collects the leaf values of m into out keyed by dotted path.
+// Empty objects are kept as leaves.
+func flattenEvent(prefix string, m map[string]interface{}, out map[string]interface{}) {
+	for k, v := range m {
+		key := prefix + k
+		if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+			flattenEvent(key+".", child, out)
+			continue
+		}
+		out[key] = v
+	}
+}
+
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -22,6 +28,23 @@ const (
 	LogLevelCritical
 )
 
+// slogLevel returns the slog level for a guest log level.
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		// Critical and unknown levels are logged above error.
+		return slog.LevelError + 4
+	}
+}
+
 type Status int32
 
 const (
@@ -31,33 +54,207 @@ const (
 	StatusNotFound
 )
 
+// sampleMessage is a msgpack message, encoded as hex, that is processed when
+// no -input is given.
+const sampleMessage = "df00000001a464617461ab68656c6c6f20776f726c64"
+
+var (
+	modulePath   string
+	inputPath    string
+	expectedPath string
+	watch        bool
+	explain      bool
+	cpuProfile   string
+	memProfile   string
+	memoryOut    string
+	seed         int64
+	traceAlloc   bool
+)
+
+func init() {
+	flag.StringVar(&modulePath, "module", "sample-wasm/target/wasm32-unknown-unknown/debug/examples/decode_msgpack.wasm", "WASM module to execute. Use - to read it from stdin.")
+	flag.StringVar(&inputPath, "input", "", "JSON file containing the event to process. Use - to read it from stdin. Defaults to a sample msgpack message.")
+	flag.StringVar(&expectedPath, "expected", "", "JSON file containing the expected event after processing. Exits non-zero if the result differs.")
+	flag.BoolVar(&explain, "explain", false, "Print the module's imports and exports and exit without running it.")
+	flag.BoolVar(&watch, "watch", false, "Re-run the module whenever the -module or -input files change.")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of the module compilation and processing to this file.")
+	flag.StringVar(&memProfile, "memprofile", "", "Write a memory profile to this file after processing.")
+	flag.StringVar(&memoryOut, "memory-out", "", "Write the guest's linear memory to this file after processing.")
+	flag.BoolVar(&traceAlloc, "trace-alloc", false, "Log each allocation the host makes in guest memory and the totals after processing.")
+	flag.Int64Var(&seed, "seed", 0, "Seed for the numbers returned by elastic_random. Defaults to a time-based seed.")
+}
+
 func main() {
-	wasmBytes, err := ioutil.ReadFile("sample-wasm/target/wasm32-unknown-unknown/debug/examples/decode_msgpack.wasm")
+	flag.Parse()
+
+	if err := validateFlags(); err != nil {
+		log.Fatal(err)
+	}
+
+	if explain {
+		wasmBytes, err := readInput(modulePath, os.Stdin)
+		if err != nil {
+			log.Fatal("Failed to read module: ", err)
+		}
+		if err = explainModule(os.Stdout, wasmBytes); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if watch {
+		if err := watchAndRun(); err != nil {
+			log.Fatal("Failed to watch files: ", err)
+		}
+		return
+	}
+
+	stopProfiling, err := startProfiling(cpuProfile, memProfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	event, err := run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = stopProfiling(); err != nil {
+		log.Fatal("Failed to write profile: ", err)
+	}
+
+	if expectedPath != "" {
+		matched, err := reportVerification(os.Stderr, event, expectedPath)
+		if err != nil {
+			log.Fatal("Failed to verify event: ", err)
+		}
+		if !matched {
+			os.Exit(1)
+		}
+	}
+}
+
+// validateFlags checks for flag combinations that cannot work together.
+func validateFlags() error {
+	if modulePath == stdinPath && inputPath == stdinPath {
+		return errors.New("-module and -input cannot both be read from stdin")
+	}
+	if watch && (modulePath == stdinPath || inputPath == stdinPath) {
+		return errors.New("-watch cannot be used when reading from stdin")
+	}
+	return nil
+}
+
+// run loads the module and input event, executes process(), and returns the
+// resulting event.
+func run() (map[string]interface{}, error) {
+	wasmBytes, err := readInput(modulePath, os.Stdin)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to read module: %w", err)
 	}
 	log.Printf("WASM size: %v", humanize.Bytes(uint64(len(wasmBytes))))
 
-	wm, err := newWasmModule(wasmBytes)
+	event := map[string]interface{}{
+		"message": sampleMessage,
+	}
+	var metadata map[string]interface{}
+	if inputPath != "" {
+		doc, err := readEvent(inputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input event: %w", err)
+		}
+		event, metadata = splitMetadata(doc)
+	}
+
+	var opts []Option
+	if seed != 0 {
+		opts = append(opts, WithRandSeed(seed))
+	}
+	if traceAlloc {
+		opts = append(opts, WithAllocationTrace())
+	}
+	wm, err := newWasmModule(wasmBytes, opts...)
 	if err != nil {
-		log.Fatal("Failed to create module:", err)
+		return nil, fmt.Errorf("failed to create module: %w", err)
 	}
+	wm.SetEvent(event)
+	wm.SetMetadata(metadata)
 
 	rtn, err := wm.process()
 	if err != nil {
-		log.Fatal("Failed to execute process().", err)
+		return nil, fmt.Errorf("failed to execute process(): %w", err)
 	}
 	log.Println("Done. Return code: ", rtn)
+
+	if memoryOut != "" {
+		if err = writeMemoryFile(wm, memoryOut); err != nil {
+			return nil, fmt.Errorf("failed to write guest memory: %w", err)
+		}
+	}
+
+	return wm.Event(), nil
 }
 
 type wasmModule struct {
+	module   *wasmer.Module
 	instance *wasmer.Instance
+	memory   *wasmer.Memory
+	event    map[string]interface{}
+	metadata map[string]interface{} // Event @metadata, kept separately from the event.
 
 	mallocFunc  wasmer.NativeFunction
 	processFunc wasmer.NativeFunction
+
+	readOnly       bool // Reject modifications to the event.
+	readOnlyStrict bool // Don't provide elastic_put_field to read-only guests.
+	ptr64          bool // Guest uses 64-bit pointers and lengths (memory64).
+
+	logger *slog.Logger // Logger for structured guest logs.
+
+	// Allocation limits per process() call. Zero means unlimited.
+	maxAllocBytes int64
+	maxAllocCalls int
+	allocBytes    int64 // Bytes allocated during the current process() call.
+	allocCalls    int   // Allocations made during the current process() call.
+	allocErr      error // Set when a limit was exceeded during the current process() call.
+	traceAlloc    bool  // Log each allocation and the process() totals.
+
+	// Size limits of the data exchanged with the guest. Zero means unlimited.
+	maxEventBytes int64 // Serialized event returned by elastic_get_event.
+	maxValueBytes int64 // Value passed to elastic_put_field.
+
+	cancelled atomic.Bool // Set when the context of the current process() call is done.
+
+	onHostCall HostCallObserver // Optional observer of host calls.
+
+	rand *rand.Rand // Source of the numbers returned by elastic_random.
+
+	snapshot []byte // Guest memory captured by Snapshot.
 }
 
-func newWasmModule(wasmData []byte) (*wasmModule, error) {
+// observe wraps the host function so that each call is reported to the
+// host call observer. The function is returned unchanged when there is no
+// observer.
+func (m *wasmModule) observe(name string, fn func([]wasmer.Value) ([]wasmer.Value, error)) func([]wasmer.Value) ([]wasmer.Value, error) {
+	if m.onHostCall == nil {
+		return fn
+	}
+	return func(args []wasmer.Value) ([]wasmer.Value, error) {
+		results, err := fn(args)
+		status := StatusInternalFailure
+		if err == nil && len(results) == 1 && results[0].Kind() == wasmer.I32 {
+			status = Status(results[0].I32())
+		}
+		m.onHostCall(name, args, status)
+		return results, err
+	}
+}
+
+// errAllocationLimit is returned when an allocation limit is exceeded. Host
+// calls that fail because of it return StatusInternalFailure rather than
+// trapping because wasmer-go crashes when finalizing traps raised by host
+// functions. process() reports the error once the guest returns.
+var errAllocationLimit = errors.New("allocation limit exceeded")
+
+func newWasmModule(wasmData []byte, opts ...Option) (*wasmModule, error) {
 	// Create an Engine
 	engine := wasmer.NewEngine()
 
@@ -70,50 +267,177 @@ func newWasmModule(wasmData []byte) (*wasmModule, error) {
 		return nil, fmt.Errorf("failed to compile module: %w", err)
 	}
 
-	wm := &wasmModule{}
+	return instantiateModule(store, module, opts...)
+}
 
-	importObject := wasmer.NewImportObject()
-	importObject.Register(
-		"elastic",
-		map[string]wasmer.IntoExtern{
-			"elastic_get_field": wasmer.NewFunction(
-				store,
-				wasmer.NewFunctionType(
-					wasmer.NewValueTypes(wasmer.I32, wasmer.I32, wasmer.I32, wasmer.I32),
-					wasmer.NewValueTypes(wasmer.I32)),
-				wm.getField,
+// newWasmModuleFromSerialized is like newWasmModule but it skips compilation
+// by deserializing a module produced by Serialize. The data must come from
+// the same wasmer version and engine.
+func newWasmModuleFromSerialized(data []byte, opts ...Option) (*wasmModule, error) {
+	store := wasmer.NewStore(wasmer.NewEngine())
+
+	module, err := wasmer.DeserializeModule(store, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize module (it must be serialized by the same wasmer version and engine): %w", err)
+	}
+
+	return instantiateModule(store, module, opts...)
+}
+
+// instantiateModule instantiates the compiled module with the host functions.
+func instantiateModule(store *wasmer.Store, module *wasmer.Module, opts ...Option) (*wasmModule, error) {
+	wm := &wasmModule{
+		module: module,
+		ptr64:  uses64BitPointers(module),
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(wm)
+	}
+	if wm.rand == nil {
+		wm.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	p := wm.ptrKind()
+
+	putField, deleteField := wm.putField, wm.deleteField
+	if wm.readOnly {
+		putField, deleteField = wm.rejectPutField, wm.rejectDeleteField
+	}
+
+	hostFunctions := map[string]wasmer.IntoExtern{
+		"elastic_get_field": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_get_field", wm.getField),
+		),
+		"elastic_get_field_v2": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_get_field_v2", wm.getFieldV2),
+		),
+		"elastic_get_event": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_get_event", wm.getEvent),
+		),
+		"elastic_list_fields": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_list_fields", wm.listFields),
+		),
+		"elastic_get_metadata": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_get_metadata", wm.getMetadata),
+		),
+		"elastic_put_field": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_put_field", putField),
+		),
+		"elastic_delete_field": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p),
+				wasmer.NewValueTypes(wasmer.I32)),
+			wm.observe("elastic_delete_field", deleteField),
+		),
+		"elastic_log": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(wasmer.I32, p, p),
+				wasmer.NewValueTypes(wasmer.I32),
+			),
+			wm.observe("elastic_log", wm.log),
+		),
+		"elastic_log_kv": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(wasmer.I32, p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32),
+			),
+			wm.observe("elastic_log_kv", wm.logKV),
+		),
+		"elastic_get_current_time_nanoseconds": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p),
+				wasmer.NewValueTypes(wasmer.I32),
 			),
-			"elastic_put_field": wasmer.NewFunction(
-				store,
-				wasmer.NewFunctionType(
-					wasmer.NewValueTypes(wasmer.I32, wasmer.I32, wasmer.I32, wasmer.I32),
-					wasmer.NewValueTypes(wasmer.I32)),
-				wm.putField,
+			wm.observe("elastic_get_current_time_nanoseconds", wm.getCurrentTime),
+		),
+		"elastic_hash": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(wasmer.I32, p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32),
 			),
-			"elastic_log": wasmer.NewFunction(
-				store,
-				wasmer.NewFunctionType(
-					wasmer.NewValueTypes(wasmer.I32, wasmer.I32, wasmer.I32),
-					wasmer.NewValueTypes(wasmer.I32),
-				),
-				wm.log,
+			wm.observe("elastic_hash", wm.hash),
+		),
+		"elastic_base64_encode": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32),
 			),
-			"elastic_get_current_time_nanoseconds": wasmer.NewFunction(
-				store,
-				wasmer.NewFunctionType(
-					wasmer.NewValueTypes(wasmer.I32),
-					wasmer.NewValueTypes(wasmer.I32),
-				),
-				wm.getCurrentTime,
+			wm.observe("elastic_base64_encode", wm.base64Encode),
+		),
+		"elastic_base64_decode": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p, p, p, p),
+				wasmer.NewValueTypes(wasmer.I32),
 			),
-		},
-	)
+			wm.observe("elastic_base64_decode", wm.base64Decode),
+		),
+		"elastic_random": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(p),
+				wasmer.NewValueTypes(wasmer.I32),
+			),
+			wm.observe("elastic_random", wm.random),
+		),
+		"elastic_should_cancel": wasmer.NewFunction(
+			store,
+			wasmer.NewFunctionType(
+				wasmer.NewValueTypes(),
+				wasmer.NewValueTypes(wasmer.I32),
+			),
+			wm.observe("elastic_should_cancel", wm.shouldCancel),
+		),
+	}
+	if wm.readOnly && wm.readOnlyStrict {
+		delete(hostFunctions, "elastic_put_field")
+		delete(hostFunctions, "elastic_delete_field")
+	}
+
+	importObject := wasmer.NewImportObject()
+	importObject.Register("elastic", hostFunctions)
 
+	var err error
 	wm.instance, err = wasmer.NewInstance(module, importObject)
 	if err != nil {
 		return nil, fmt.Errorf("failed to instantiate the module: %w", err)
 	}
 
+	wm.memory, err = wm.instance.Exports.GetMemory("memory")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the `memory` memory: %w", err)
+	}
+
 	wm.mallocFunc, err = wm.instance.Exports.GetFunction("malloc")
 	if err != nil {
 		return nil, fmt.Errorf("failed to find malloc export: %w", err)
@@ -127,48 +451,156 @@ func newWasmModule(wasmData []byte) (*wasmModule, error) {
 	return wm, nil
 }
 
+// Serialize returns the compiled module in a form that
+// newWasmModuleFromSerialized can load without compiling it again.
+func (m *wasmModule) Serialize() ([]byte, error) {
+	return m.module.Serialize()
+}
+
+// Event returns the event that the guest operates on.
+func (m *wasmModule) Event() map[string]interface{} {
+	return m.event
+}
+
+// SetEvent sets the event that the guest operates on.
+func (m *wasmModule) SetEvent(event map[string]interface{}) {
+	m.event = event
+}
+
+// Metadata returns the event metadata that is available to the guest through
+// elastic_get_metadata.
+func (m *wasmModule) Metadata() map[string]interface{} {
+	return m.metadata
+}
+
+// SetMetadata sets the event metadata.
+func (m *wasmModule) SetMetadata(metadata map[string]interface{}) {
+	m.metadata = metadata
+}
+
 func (m *wasmModule) getField(args []wasmer.Value) ([]wasmer.Value, error) {
 	if len(args) != 4 {
 		return nil, fmt.Errorf("get_field requires 4 arguments, but got %d", len(args))
 	}
 
-	dataPtr := args[0].I32()
-	dataLen := args[1].I32()
-	rtnPtr := args[2].I32()
-	rtnLen := args[3].I32()
+	status, _, err := m.lookupField("get_field", m.event, m.ptrArg(args[0]), m.ptrArg(args[1]), m.ptrArg(args[2]), m.ptrArg(args[3]))
+	if err != nil {
+		return nil, err
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(status))}, nil
+}
 
-	memory, err := m.instance.Exports.GetMemory("memory")
+// getEvent writes the whole event, encoded as JSON, into guest memory. The
+// arguments are the out-pointers for the buffer's pointer and length.
+func (m *wasmModule) getEvent(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("get_event requires 2 arguments, but got %d", len(args))
+	}
+
+	data, err := json.Marshal(m.event)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the `memory` memory: %w", err)
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, nil
+	}
+	log.Printf("get_event: %d bytes", len(data))
+	if m.maxEventBytes > 0 && int64(len(data)) > m.maxEventBytes {
+		log.Printf("get_event: rejected, event exceeds the limit of %d bytes", m.maxEventBytes)
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, nil
 	}
 
-	data := memory.Data()[dataPtr : dataPtr+dataLen]
-	log.Println("get_field: ", string(data))
+	if err = m.writeGuestBuffer(data, m.ptrArg(args[0]), m.ptrArg(args[1])); err != nil {
+		if errors.Is(err, errAllocationLimit) {
+			return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, nil
+		}
+		return nil, err
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
+}
 
-	if string(data) == "message" {
-		raw := "df00000001a464617461ab68656c6c6f20776f726c64"
-		value, err := json.Marshal(raw)
-		if err != nil {
-			return nil, err
+// listFields writes the dotted keys of the event's fields, as a JSON array of
+// strings, into newly allocated guest memory. See fieldKeys.
+func (m *wasmModule) listFields(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("list_fields requires 2 arguments, but got %d", len(args))
+	}
+
+	keys := fieldKeys(m.event)
+	if keys == nil {
+		keys = []string{}
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, nil
+	}
+
+	if err = m.writeGuestBuffer(data, m.ptrArg(args[0]), m.ptrArg(args[1])); err != nil {
+		if errors.Is(err, errAllocationLimit) {
+			return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, nil
 		}
+		return nil, err
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
+}
 
-		valueSize := int32(len(value))
+// getMetadata is like getField but it reads from the event's @metadata.
+func (m *wasmModule) getMetadata(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("get_metadata requires 4 arguments, but got %d", len(args))
+	}
 
-		valuePtr, err := m.malloc(valueSize)
-		if err != nil {
+	status, _, err := m.lookupField("get_metadata", m.metadata, m.ptrArg(args[0]), m.ptrArg(args[1]), m.ptrArg(args[2]), m.ptrArg(args[3]))
+	if err != nil {
+		return nil, err
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(status))}, nil
+}
+
+// getFieldV2 is like getField but it additionally writes the FieldType of the
+// value into the guest pointer given as the fifth argument.
+func (m *wasmModule) getFieldV2(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 5 {
+		return nil, fmt.Errorf("get_field_v2 requires 5 arguments, but got %d", len(args))
+	}
+
+	status, value, err := m.lookupField("get_field_v2", m.event, m.ptrArg(args[0]), m.ptrArg(args[1]), m.ptrArg(args[2]), m.ptrArg(args[3]))
+	if err != nil {
+		return nil, err
+	}
+
+	if status == StatusOK {
+		if err = m.putUint32(m.ptrArg(args[4]), uint32(fieldTypeOf(value))); err != nil {
 			return nil, err
 		}
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(status))}, nil
+}
 
-		// Copy into allocated memory.
-		copy(memory.Data()[valuePtr:valuePtr+valueSize], value)
+// lookupField reads the key from guest memory, looks it up in fields, and
+// writes the JSON encoded value into guest memory. The name of the host call
+// is used for logging.
+func (m *wasmModule) lookupField(name string, fields map[string]interface{}, keyPtr, keyLen, rtnPtr, rtnLen int64) (Status, interface{}, error) {
+	key, err := m.guestBytes(keyPtr, keyLen)
+	if err != nil {
+		return StatusInvalidArgument, nil, err
+	}
+	log.Printf("%s: %s", name, key)
 
-		binary.LittleEndian.PutUint32(memory.Data()[rtnPtr:rtnPtr+4], uint32(valuePtr))
-		binary.LittleEndian.PutUint32(memory.Data()[rtnLen:rtnLen+4], uint32(valueSize))
+	v, found := getValue(fields, string(key))
+	if !found {
+		return StatusNotFound, nil, nil
+	}
 
-		return []wasmer.Value{wasmer.NewI32(0)}, nil
+	value, err := json.Marshal(v)
+	if err != nil {
+		return StatusInternalFailure, nil, err
 	}
 
-	return []wasmer.Value{wasmer.NewI32(0)}, nil
+	if err = m.writeGuestBuffer(value, rtnPtr, rtnLen); err != nil {
+		if errors.Is(err, errAllocationLimit) {
+			return StatusInternalFailure, nil, nil
+		}
+		return StatusInternalFailure, nil, err
+	}
+	return StatusOK, v, nil
 }
 
 func (m *wasmModule) putField(args []wasmer.Value) ([]wasmer.Value, error) {
@@ -176,18 +608,23 @@ func (m *wasmModule) putField(args []wasmer.Value) ([]wasmer.Value, error) {
 		return nil, fmt.Errorf("put_field requires 4 arguments, but got %d", len(args))
 	}
 
-	keyPtr := args[0].I32()
-	keyLen := args[1].I32()
-	valuePtr := args[2].I32()
-	valueLen := args[3].I32()
+	keyPtr := m.ptrArg(args[0])
+	keyLen := m.ptrArg(args[1])
+	valuePtr := m.ptrArg(args[2])
+	valueLen := m.ptrArg(args[3])
 
-	memory, err := m.instance.Exports.GetMemory("memory")
+	key, err := m.guestBytes(keyPtr, keyLen)
 	if err != nil {
-		return []wasmer.Value{wasmer.NewI32(int32(StatusInternalFailure))}, fmt.Errorf("failed to get the `memory` memory: %w", err)
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, err
+	}
+	if m.maxValueBytes > 0 && valueLen > m.maxValueBytes {
+		log.Printf("put_field: rejected, %s value of %d bytes exceeds the limit of %d bytes", key, valueLen, m.maxValueBytes)
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+	value, err := m.guestBytes(valuePtr, valueLen)
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, err
 	}
-
-	key := memory.Data()[keyPtr : keyPtr+keyLen]
-	value := memory.Data()[valuePtr : valuePtr+valueLen]
 	log.Println("put_field: ", string(key), string(value))
 
 	var v interface{}
@@ -196,57 +633,204 @@ func (m *wasmModule) putField(args []wasmer.Value) ([]wasmer.Value, error) {
 	}
 
 	log.Printf("put_field: %s=%+v", key, v)
+	if m.event == nil {
+		m.event = map[string]interface{}{}
+	}
+	putValue(m.event, string(key), v)
 
 	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
 }
 
+// rejectPutField replaces putField for read-only guests.
+func (m *wasmModule) rejectPutField(args []wasmer.Value) ([]wasmer.Value, error) {
+	log.Println("put_field: rejected, event is read-only")
+	return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+}
+
+// deleteField removes the (possibly dotted) key from the event. It returns
+// StatusNotFound if the key does not exist.
+func (m *wasmModule) deleteField(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("delete_field requires 2 arguments, but got %d", len(args))
+	}
+
+	key, err := m.guestBytes(m.ptrArg(args[0]), m.ptrArg(args[1]))
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, err
+	}
+	log.Println("delete_field: ", string(key))
+
+	if !deleteValue(m.event, string(key)) {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusNotFound))}, nil
+	}
+	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
+}
+
+// rejectDeleteField replaces deleteField for read-only guests.
+func (m *wasmModule) rejectDeleteField(args []wasmer.Value) ([]wasmer.Value, error) {
+	log.Println("delete_field: rejected, event is read-only")
+	return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+}
+
 func (m *wasmModule) log(args []wasmer.Value) ([]wasmer.Value, error) {
 	if len(args) != 3 {
 		return nil, fmt.Errorf("log requires 3 arguments, but got %d", len(args))
 	}
 
 	level := args[0].I32()
-	dataPtr := args[1].I32()
-	dataLen := args[2].I32()
+	dataPtr := m.ptrArg(args[1])
+	dataLen := m.ptrArg(args[2])
 
-	memory, err := m.instance.Exports.GetMemory("memory")
+	data, err := m.guestBytes(dataPtr, dataLen)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the `memory` memory: %w", err)
+		return nil, err
 	}
-
-	data := memory.Data()[dataPtr : dataPtr+dataLen]
 	log.Printf("log[%d]: %s", level, string(data))
 	return []wasmer.Value{wasmer.NewI32(0)}, nil
 }
 
+// logKV logs a guest message with structured attributes. The arguments are
+// the level, the message, and a JSON object whose keys and values become the
+// log attributes.
+func (m *wasmModule) logKV(args []wasmer.Value) ([]wasmer.Value, error) {
+	if len(args) != 5 {
+		return nil, fmt.Errorf("log_kv requires 5 arguments, but got %d", len(args))
+	}
+
+	level := LogLevel(args[0].I32())
+	msg, err := m.guestBytes(m.ptrArg(args[1]), m.ptrArg(args[2]))
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+	fieldsJSON, err := m.guestBytes(m.ptrArg(args[3]), m.ptrArg(args[4]))
+	if err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(fieldsJSON, &fields); err != nil {
+		return []wasmer.Value{wasmer.NewI32(int32(StatusInvalidArgument))}, nil
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+
+	m.logger.LogAttrs(context.Background(), level.slogLevel(), string(msg), attrs...)
+	return []wasmer.Value{wasmer.NewI32(int32(StatusOK))}, nil
+}
+
 func (m *wasmModule) getCurrentTime(args []wasmer.Value) ([]wasmer.Value, error) {
 	if len(args) != 1 {
 		return nil, fmt.Errorf("elastic_get_current_time_nanoseconds requires 1 arguments, but got %d", len(args))
 	}
 
-	ptr := args[0].I32()
+	ptr := m.ptrArg(args[0])
 
-	memory, err := m.instance.Exports.GetMemory("memory")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get the `memory` memory: %w", err)
+	if err := m.putUint64(ptr, uint64(time.Now().UnixNano())); err != nil {
+		return nil, err
 	}
-
-	binary.LittleEndian.PutUint64(memory.Data()[ptr:ptr+8], uint64(time.Now().UnixNano()))
 	return []wasmer.Value{wasmer.NewI32(0)}, nil
 }
 
-func (m *wasmModule) malloc(size int32) (wasmPointer int32, err error) {
-	ptr, err := m.mallocFunc(size)
-	if err != nil {
-		return 0, err
+func (m *wasmModule) malloc(size int64) (wasmPointer int64, err error) {
+	if m.maxAllocCalls > 0 && m.allocCalls+1 > m.maxAllocCalls {
+		m.allocErr = fmt.Errorf("%w: more than %d allocations", errAllocationLimit, m.maxAllocCalls)
+		return 0, m.allocErr
+	}
+	if m.maxAllocBytes > 0 && m.allocBytes+size > m.maxAllocBytes {
+		m.allocErr = fmt.Errorf("%w: more than %d bytes", errAllocationLimit, m.maxAllocBytes)
+		return 0, m.allocErr
 	}
-	return ptr.(int32), nil
+	m.allocCalls++
+	m.allocBytes += size
+
+	if m.ptr64 {
+		ptr, err := m.mallocFunc(size)
+		if err != nil {
+			return 0, err
+		}
+		wasmPointer = ptr.(int64)
+	} else {
+		ptr, err := m.mallocFunc(int32(size))
+		if err != nil {
+			return 0, err
+		}
+		wasmPointer = int64(uint32(ptr.(int32)))
+	}
+
+	if m.traceAlloc {
+		m.logger.Info("malloc", "size", size, "ptr", wasmPointer)
+	}
+	return wasmPointer, nil
+}
+
+// shouldCancel returns 1 if the host has requested that the guest stop
+// processing, otherwise 0. Guests poll it in long running loops.
+func (m *wasmModule) shouldCancel(args []wasmer.Value) ([]wasmer.Value, error) {
+	if m.cancelled.Load() {
+		return []wasmer.Value{wasmer.NewI32(1)}, nil
+	}
+	return []wasmer.Value{wasmer.NewI32(0)}, nil
 }
 
 func (m *wasmModule) process() (int32, error) {
+	return m.processContext(context.Background())
+}
+
+// processContext is like process but cancelling ctx requests that the guest
+// stop early. Cancellation is cooperative: it is observed only by guests that
+// poll elastic_should_cancel.
+func (m *wasmModule) processContext(ctx context.Context) (int32, error) {
+	m.cancelled.Store(ctx.Err() != nil)
+	stop := context.AfterFunc(ctx, func() { m.cancelled.Store(true) })
+	defer stop()
+
+	m.allocBytes, m.allocCalls, m.allocErr = 0, 0, nil
+
 	rtn, err := m.processFunc()
+	if m.traceAlloc {
+		// The guest owns the buffers once they are returned and frees them
+		// without telling the host, so these are outstanding from the host's
+		// point of view.
+		m.logger.Info("outstanding allocations", "allocations", m.allocCalls, "bytes", m.allocBytes)
+	}
 	if err != nil {
 		return 0, err
 	}
+	if m.allocErr != nil {
+		return 0, m.allocErr
+	}
 	return rtn.(int32), nil
 }
+
+// CallExport invokes the exported guest function with the given name. The
+// native function checks the number of arguments and converts them to the
+// function's parameter types, and the result is returned as a native Go value
+// (nil for no results, a []interface{} for multiple results).
+func (m *wasmModule) CallExport(name string, args ...interface{}) (interface{}, error) {
+	export, err := m.instance.Exports.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s export: %w", name, err)
+	}
+	// GetFunction panics if the export is not a function.
+	if export.Kind() != wasmer.FUNCTION {
+		return nil, fmt.Errorf("%s export is a %s, not a function", name, export.Kind())
+	}
+	fn, err := m.instance.Exports.GetFunction(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s export: %w", name, err)
+	}
+
+	rtn, err := fn(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s export: %w", name, err)
+	}
+	return rtn, nil
+}
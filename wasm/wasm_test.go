@@ -0,0 +1,779 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// testGuest returns the WAT for a guest module that imports the given host
+// functions and exports its memory, a bump allocator as malloc (growing memory
+// as needed), and the given definitions.
+func testGuest(imports, defs string) string {
+	return "(module\n" + imports + `
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+  (func (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (global.get $heap) (local.get $size)))
+    (if (i32.gt_u (global.get $heap) (i32.mul (memory.size) (i32.const 65536)))
+      (then
+        (drop (memory.grow
+          (i32.add (i32.const 1)
+            (i32.div_u (i32.sub (global.get $heap) (i32.mul (memory.size) (i32.const 65536))) (i32.const 65536)))))))
+    (local.get $ptr))
+` + defs + ")\n"
+}
+
+// addGuest is a minimal guest that satisfies the runner's required exports
+// and additionally exports an add function.
+var addGuest = testGuest("", `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "add") (param i32 i32) (result i32)
+    (i32.add (local.get 0) (local.get 1)))
+`)
+
+func newTestModule(t testing.TB, wat string, opts ...Option) *wasmModule {
+	t.Helper()
+
+	wasmBytes, err := wasmer.Wat2Wasm(wat)
+	require.NoError(t, err)
+
+	wm, err := newWasmModule(wasmBytes, opts...)
+	require.NoError(t, err)
+	return wm
+}
+
+func TestCallExport(t *testing.T) {
+	wm := newTestModule(t, addGuest)
+
+	rtn, err := wm.CallExport("add", int32(2), int32(3))
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), rtn)
+
+	_, err = wm.CallExport("subtract", int32(2), int32(3))
+	assert.ErrorContains(t, err, "failed to find subtract export")
+
+	_, err = wm.CallExport("add", int32(2))
+	assert.ErrorContains(t, err, "Expected 2 argument(s), received 1")
+
+	_, err = wm.CallExport("add", "two", int32(3))
+	assert.Error(t, err)
+
+	_, err = wm.CallExport("memory")
+	assert.ErrorContains(t, err, "memory export is a memory, not a function")
+}
+
+// getFieldTypeGuest exports get_type which calls elastic_get_field_v2 for the
+// key at the given pointer and returns the type tag, or -1 if the status was
+// not OK.
+var getFieldTypeGuest = testGuest(`
+  (import "elastic" "elastic_get_field_v2" (func $get_field_v2 (param i32 i32 i32 i32 i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "get_type") (param $key i32) (param $len i32) (result i32)
+    (if (i32.ne (call $get_field_v2 (local.get $key) (local.get $len) (i32.const 0) (i32.const 4) (i32.const 8)) (i32.const 0))
+      (then (return (i32.const -1))))
+    (i32.load (i32.const 8)))
+`)
+
+// writeGuestString copies s into memory allocated by the guest's malloc.
+func writeGuestString(t testing.TB, wm *wasmModule, s string) (ptr, length int32) {
+	t.Helper()
+
+	p, err := wm.malloc(int64(len(s)))
+	require.NoError(t, err)
+
+	buf, err := wm.guestBytes(p, int64(len(s)))
+	require.NoError(t, err)
+	copy(buf, s)
+
+	return int32(p), int32(len(s))
+}
+
+func TestGetFieldV2(t *testing.T) {
+	wm := newTestModule(t, getFieldTypeGuest)
+	wm.SetEvent(map[string]interface{}{
+		"message": "hello",
+		"event": map[string]interface{}{
+			"duration": float64(1234),
+		},
+	})
+
+	testCases := []struct {
+		key      string
+		expected int32
+	}{
+		{"message", int32(FieldTypeString)},
+		{"event.duration", int32(FieldTypeNumber)},
+		{"event", int32(FieldTypeObject)},
+		{"missing", -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			ptr, length := writeGuestString(t, wm, tc.key)
+
+			rtn, err := wm.CallExport("get_type", ptr, length)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rtn)
+		})
+	}
+}
+
+// putFieldGuest's process sets message to "changed".
+var putFieldGuest = testGuest(`
+  (import "elastic" "elastic_put_field" (func $put_field (param i32 i32 i32 i32) (result i32)))
+`, `
+  (data (i32.const 0) "message")
+  (data (i32.const 16) "\"changed\"")
+  (func (export "process") (result i32)
+    (call $put_field (i32.const 0) (i32.const 7) (i32.const 16) (i32.const 9)))
+`)
+
+func TestReadOnlyEvent(t *testing.T) {
+	t.Run("writable", func(t *testing.T) {
+		wm := newTestModule(t, putFieldGuest)
+		wm.SetEvent(map[string]interface{}{"message": "original"})
+
+		rtn, err := wm.process()
+		require.NoError(t, err)
+		assert.Equal(t, int32(StatusOK), rtn)
+		assert.Equal(t, "changed", wm.Event()["message"])
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		wm := newTestModule(t, putFieldGuest, WithReadOnlyEvent(false))
+		wm.SetEvent(map[string]interface{}{"message": "original"})
+
+		rtn, err := wm.process()
+		require.NoError(t, err)
+		assert.Equal(t, int32(StatusInvalidArgument), rtn)
+		assert.Equal(t, "original", wm.Event()["message"])
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		wasmBytes, err := wasmer.Wat2Wasm(putFieldGuest)
+		require.NoError(t, err)
+
+		_, err = newWasmModule(wasmBytes, WithReadOnlyEvent(true))
+		assert.ErrorContains(t, err, "failed to instantiate the module")
+	})
+}
+
+func TestSizeLimits(t *testing.T) {
+	t.Run("put_field", func(t *testing.T) {
+		// The guest puts the 9 byte value "changed".
+		wm := newTestModule(t, putFieldGuest, WithSizeLimits(0, 9))
+		wm.SetEvent(map[string]interface{}{"message": "original"})
+
+		rtn, err := wm.process()
+		require.NoError(t, err)
+		assert.Equal(t, int32(StatusOK), rtn)
+		assert.Equal(t, "changed", wm.Event()["message"])
+
+		wm = newTestModule(t, putFieldGuest, WithSizeLimits(0, 8))
+		wm.SetEvent(map[string]interface{}{"message": "original"})
+
+		rtn, err = wm.process()
+		require.NoError(t, err)
+		assert.Equal(t, int32(StatusInvalidArgument), rtn)
+		assert.Equal(t, "original", wm.Event()["message"])
+	})
+
+	t.Run("get_event", func(t *testing.T) {
+		event := map[string]interface{}{"message": "hello"}
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+
+		wm := newTestModule(t, getEventGuest, WithSizeLimits(int64(len(data)), 0))
+		wm.SetEvent(event)
+		rtn, err := wm.CallExport("get_event")
+		require.NoError(t, err)
+		assert.Equal(t, int32(StatusOK), rtn)
+
+		wm.SetEvent(map[string]interface{}{"message": "hello world"})
+		rtn, err = wm.CallExport("get_event")
+		require.NoError(t, err)
+		assert.Equal(t, int32(StatusInternalFailure), rtn)
+	})
+}
+
+func TestVerifyEvent(t *testing.T) {
+	event, err := readEvent("testdata/event.json")
+	require.NoError(t, err)
+
+	wm := newTestModule(t, putFieldGuest)
+	wm.SetEvent(event)
+
+	_, err = wm.process()
+	require.NoError(t, err)
+
+	t.Run("match", func(t *testing.T) {
+		diffs, err := verifyEvent(wm.Event(), "testdata/expected_match.json")
+		require.NoError(t, err)
+		assert.Empty(t, diffs)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		diffs, err := verifyEvent(wm.Event(), "testdata/expected_mismatch.json")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			`+ event.kind: "event"`,
+			`- event.outcome: "success"`,
+			`~ message: expected "expected", got "changed"`,
+		}, diffs)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		var buf bytes.Buffer
+		matched, err := reportVerification(&buf, wm.Event(), "testdata/expected_match.json")
+		require.NoError(t, err)
+		assert.True(t, matched)
+		assert.Zero(t, buf.Len())
+
+		matched, err = reportVerification(&buf, wm.Event(), "testdata/expected_mismatch.json")
+		require.NoError(t, err)
+		assert.False(t, matched)
+		assert.Equal(t, "Event does not match testdata/expected_mismatch.json:\n"+
+			"+ event.kind: \"event\"\n"+
+			"- event.outcome: \"success\"\n"+
+			"~ message: expected \"expected\", got \"changed\"\n", buf.String())
+	})
+}
+
+// getMetadataGuest exports get_field and get_metadata. Both take a key and
+// return the host call's status. A returned value is stored at 0 (pointer)
+// and 4 (length).
+var getMetadataGuest = testGuest(`
+  (import "elastic" "elastic_get_field" (func $get_field (param i32 i32 i32 i32) (result i32)))
+  (import "elastic" "elastic_get_metadata" (func $get_metadata (param i32 i32 i32 i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "get_field") (param $key i32) (param $len i32) (result i32)
+    (call $get_field (local.get $key) (local.get $len) (i32.const 0) (i32.const 4)))
+  (func (export "get_metadata") (param $key i32) (param $len i32) (result i32)
+    (call $get_metadata (local.get $key) (local.get $len) (i32.const 0) (i32.const 4)))
+`)
+
+// readGuestResult returns the buffer whose pointer and length the guest
+// stored at rtnPtr and rtnLen.
+func readGuestResult(t testing.TB, wm *wasmModule, rtnPtr, rtnLen int64) string {
+	t.Helper()
+
+	readPtr := func(ptr int64) int64 {
+		if wm.ptr64 {
+			b, err := wm.guestBytes(ptr, 8)
+			require.NoError(t, err)
+			return int64(binary.LittleEndian.Uint64(b))
+		}
+		b, err := wm.guestBytes(ptr, 4)
+		require.NoError(t, err)
+		return int64(binary.LittleEndian.Uint32(b))
+	}
+
+	data, err := wm.guestBytes(readPtr(rtnPtr), readPtr(rtnLen))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestGetMetadata(t *testing.T) {
+	doc, err := readEvent("testdata/document_with_metadata.json")
+	require.NoError(t, err)
+
+	event, metadata := splitMetadata(doc)
+	assert.NotContains(t, event, "@metadata")
+
+	wm := newTestModule(t, getMetadataGuest)
+	wm.SetEvent(event)
+	wm.SetMetadata(metadata)
+
+	ptr, length := writeGuestString(t, wm, "beat")
+	rtn, err := wm.CallExport("get_metadata", ptr, length)
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, `"filebeat"`, readGuestResult(t, wm, 0, 4))
+
+	ptr, length = writeGuestString(t, wm, "@metadata.beat")
+	rtn, err = wm.CallExport("get_field", ptr, length)
+	require.NoError(t, err)
+	assert.Equal(t, int32(StatusNotFound), rtn)
+
+	ptr, length = writeGuestString(t, wm, "message")
+	rtn, err = wm.CallExport("get_field", ptr, length)
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, `"hello"`, readGuestResult(t, wm, 0, 4))
+}
+
+// getEventGuest exports get_event which stores the event's pointer and length
+// at 0 and 4 and returns the host call's status.
+var getEventGuest = testGuest(`
+  (import "elastic" "elastic_get_event" (func $get_event (param i32 i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "get_event") (result i32)
+    (call $get_event (i32.const 0) (i32.const 4)))
+`)
+
+func TestGetEvent(t *testing.T) {
+	testCases := map[string]map[string]interface{}{
+		"small": {
+			"message": "hello",
+			"event":   map[string]interface{}{"kind": "event"},
+		},
+		"large": {
+			"message": strings.Repeat("x", 256*1024),
+		},
+	}
+
+	for name, event := range testCases {
+		t.Run(name, func(t *testing.T) {
+			wm := newTestModule(t, getEventGuest)
+			wm.SetEvent(event)
+
+			rtn, err := wm.CallExport("get_event")
+			require.NoError(t, err)
+			require.Equal(t, int32(StatusOK), rtn)
+
+			expected, err := json.Marshal(event)
+			require.NoError(t, err)
+
+			data := readGuestResult(t, wm, 0, 4)
+			assert.Len(t, data, len(expected))
+			assert.JSONEq(t, string(expected), data)
+		})
+	}
+}
+
+// deleteFieldGuest exports delete_field which deletes the key at the given
+// guest pointer and length.
+var deleteFieldGuest = testGuest(`
+  (import "elastic" "elastic_delete_field" (func $delete_field (param i32 i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "delete_field") (param $ptr i32) (param $len i32) (result i32)
+    (call $delete_field (local.get $ptr) (local.get $len)))
+`)
+
+func TestDeleteField(t *testing.T) {
+	newModule := func(t *testing.T, opts ...Option) *wasmModule {
+		wm := newTestModule(t, deleteFieldGuest, opts...)
+		wm.SetEvent(map[string]interface{}{
+			"message": "hello",
+			"event":   map[string]interface{}{"kind": "event", "action": "login"},
+		})
+		return wm
+	}
+
+	deleteField := func(t *testing.T, wm *wasmModule, key string) interface{} {
+		ptr, length := writeGuestString(t, wm, key)
+		rtn, err := wm.CallExport("delete_field", ptr, length)
+		require.NoError(t, err)
+		return rtn
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		wm := newModule(t)
+		assert.Equal(t, int32(StatusOK), deleteField(t, wm, "event.action"))
+		assert.Equal(t, map[string]interface{}{
+			"message": "hello",
+			"event":   map[string]interface{}{"kind": "event"},
+		}, wm.Event())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		wm := newModule(t)
+		assert.Equal(t, int32(StatusNotFound), deleteField(t, wm, "event.outcome"))
+		assert.Equal(t, int32(StatusNotFound), deleteField(t, wm, "message.text"))
+		assert.Equal(t, int32(StatusNotFound), deleteField(t, wm, "host.name"))
+		assert.Len(t, wm.Event(), 2)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		wm := newModule(t, WithReadOnlyEvent(false))
+		assert.Equal(t, int32(StatusInvalidArgument), deleteField(t, wm, "message"))
+		assert.Contains(t, wm.Event(), "message")
+	})
+}
+
+// getField64Guest is a guest that passes 64-bit pointers and lengths, as
+// guests compiled for memory64 do. Its memory is 32-bit because the runtime
+// does not support the memory64 proposal, but the host calls are the same.
+var getField64Guest = `(module
+  (import "elastic" "elastic_get_field" (func $get_field (param i64 i64 i64 i64) (result i32)))
+  (memory (export "memory") 1)
+  (global $heap (mut i64) (i64.const 1024))
+  (func (export "malloc") (param $size i64) (result i64)
+    (local $ptr i64)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i64.add (global.get $heap) (local.get $size)))
+    (local.get $ptr))
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "get_field") (param $key i64) (param $len i64) (result i32)
+    (call $get_field (local.get $key) (local.get $len) (i64.const 0) (i64.const 8))))
+`
+
+func TestGetField64BitPointers(t *testing.T) {
+	wm := newTestModule(t, getField64Guest)
+	require.True(t, wm.ptr64)
+	wm.SetEvent(map[string]interface{}{
+		"event": map[string]interface{}{"action": "login"},
+	})
+
+	ptr, length := writeGuestString(t, wm, "event.action")
+	rtn, err := wm.CallExport("get_field", int64(ptr), int64(length))
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, `"login"`, readGuestResult(t, wm, 0, 8))
+
+	// 32-bit guests are unaffected.
+	assert.False(t, newTestModule(t, addGuest).ptr64)
+}
+
+// logKVGuest exports log_kv which logs the message and JSON fields at the
+// given pointers at warn level.
+var logKVGuest = testGuest(`
+  (import "elastic" "elastic_log_kv" (func $log_kv (param i32 i32 i32 i32 i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "log_kv") (param $msg i32) (param $msgLen i32) (param $fields i32) (param $fieldsLen i32) (result i32)
+    (call $log_kv (i32.const 2) (local.get $msg) (local.get $msgLen) (local.get $fields) (local.get $fieldsLen)))
+`)
+
+func TestLogKV(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	wm := newTestModule(t, logKVGuest, WithLogger(logger))
+
+	logKV := func(msg, fields string) interface{} {
+		msgPtr, msgLen := writeGuestString(t, wm, msg)
+		fieldsPtr, fieldsLen := writeGuestString(t, wm, fields)
+		rtn, err := wm.CallExport("log_kv", msgPtr, msgLen, fieldsPtr, fieldsLen)
+		require.NoError(t, err)
+		return rtn
+	}
+
+	assert.Equal(t, int32(StatusOK), logKV("decoded message", `{"bytes": 42, "codec": "msgpack"}`))
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "decoded message", record["msg"])
+	assert.Equal(t, float64(42), record["bytes"])
+	assert.Equal(t, "msgpack", record["codec"])
+
+	buf.Reset()
+	assert.Equal(t, int32(StatusInvalidArgument), logKV("bad", `{"bytes":`))
+	assert.Equal(t, int32(StatusInvalidArgument), logKV("bad", `["not", "an", "object"]`))
+	assert.Zero(t, buf.Len())
+}
+
+// getEventLoopGuest calls elastic_get_event the number of times given by the
+// global count during process().
+var getEventLoopGuest = testGuest(`
+  (import "elastic" "elastic_get_event" (func $get_event (param i32 i32) (result i32)))
+`, `
+  (global $count (export "count") (mut i32) (i32.const 1000))
+  (func (export "process") (result i32)
+    (local $i i32)
+    (block $done
+      (loop $next
+        (br_if $done (i32.ge_u (local.get $i) (global.get $count)))
+        (drop (call $get_event (i32.const 0) (i32.const 4)))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $next)))
+    (i32.const 0))
+`)
+
+func TestAllocationLimit(t *testing.T) {
+	event := map[string]interface{}{"message": "hello"} // 19 bytes as JSON.
+
+	t.Run("unlimited", func(t *testing.T) {
+		wm := newTestModule(t, getEventLoopGuest)
+		wm.SetEvent(event)
+		_, err := wm.process()
+		require.NoError(t, err)
+	})
+
+	t.Run("calls", func(t *testing.T) {
+		wm := newTestModule(t, getEventLoopGuest, WithAllocationLimit(0, 10))
+		wm.SetEvent(event)
+		_, err := wm.process()
+		assert.ErrorContains(t, err, "more than 10 allocations")
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		wm := newTestModule(t, getEventLoopGuest, WithAllocationLimit(1024, 0))
+		wm.SetEvent(event)
+		_, err := wm.process()
+		assert.ErrorContains(t, err, "more than 1024 bytes")
+	})
+
+	t.Run("reset per process", func(t *testing.T) {
+		wm := newTestModule(t, getEventLoopGuest, WithAllocationLimit(0, 1000))
+		wm.SetEvent(event)
+		for i := 0; i < 3; i++ {
+			_, err := wm.process()
+			require.NoError(t, err)
+		}
+	})
+}
+
+// shouldCancelGuest's process polls elastic_should_cancel until it is set,
+// giving up after 2^31 polls. It returns 1 if it observed the cancellation.
+var shouldCancelGuest = testGuest(`
+  (import "elastic" "elastic_should_cancel" (func $should_cancel (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (local $i i32)
+    (block $done
+      (loop $poll
+        (br_if $done (i32.lt_s (local.get $i) (i32.const 0)))
+        (if (call $should_cancel) (then (return (i32.const 1))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $poll)))
+    (i32.const 0))
+`)
+
+func TestShouldCancel(t *testing.T) {
+	wm := newTestModule(t, shouldCancelGuest)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	rtn, err := wm.processContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), rtn)
+
+	// An already cancelled context is observed on the first poll.
+	rtn, err = wm.processContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), rtn)
+
+	// The flag is reset for each call.
+	wm = newTestModule(t, addGuest)
+	wm.cancelled.Store(true)
+	_, err = wm.processContext(context.Background())
+	require.NoError(t, err)
+	assert.False(t, wm.cancelled.Load())
+}
+
+// messageGuest's process reads message and a missing field, then sets
+// message to "changed".
+var messageGuest = testGuest(`
+  (import "elastic" "elastic_get_field" (func $get_field (param i32 i32 i32 i32) (result i32)))
+  (import "elastic" "elastic_put_field" (func $put_field (param i32 i32 i32 i32) (result i32)))
+`, `
+  (data (i32.const 0) "message")
+  (data (i32.const 16) "\"changed\"")
+  (data (i32.const 32) "missing")
+  (func (export "process") (result i32)
+    (drop (call $get_field (i32.const 0) (i32.const 7) (i32.const 48) (i32.const 52)))
+    (drop (call $get_field (i32.const 32) (i32.const 7) (i32.const 48) (i32.const 52)))
+    (call $put_field (i32.const 0) (i32.const 7) (i32.const 16) (i32.const 9)))
+`)
+
+func TestHostCallObserver(t *testing.T) {
+	var wm *wasmModule
+	var calls []string
+	wm = newTestModule(t, messageGuest, WithHostCallObserver(func(name string, args []wasmer.Value, status Status) {
+		key, err := wm.guestBytes(wm.ptrArg(args[0]), wm.ptrArg(args[1]))
+		require.NoError(t, err)
+		calls = append(calls, fmt.Sprintf("%s(%s) = %d", name, key, status))
+	}))
+	wm.SetEvent(map[string]interface{}{"message": "original"})
+
+	rtn, err := wm.process()
+	require.NoError(t, err)
+	assert.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, "changed", wm.Event()["message"])
+
+	assert.Equal(t, []string{
+		fmt.Sprintf("elastic_get_field(message) = %d", StatusOK),
+		fmt.Sprintf("elastic_get_field(missing) = %d", StatusNotFound),
+		fmt.Sprintf("elastic_put_field(message) = %d", StatusOK),
+	}, calls)
+}
+
+func TestAllocationTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	wm := newTestModule(t, messageGuest, WithLogger(logger), WithAllocationTrace())
+	wm.SetEvent(map[string]interface{}{"message": "original"})
+
+	_, err := wm.process()
+	require.NoError(t, err)
+
+	var records []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record map[string]interface{}
+		require.NoError(t, dec.Decode(&record))
+		delete(record, "time")
+		records = append(records, record)
+	}
+
+	// Only the "message" lookup allocates.
+	size := float64(len(`"original"`))
+	assert.Equal(t, []map[string]interface{}{
+		{"level": "INFO", "msg": "malloc", "size": size, "ptr": float64(1024)},
+		{"level": "INFO", "msg": "outstanding allocations", "allocations": float64(1), "bytes": size},
+	}, records)
+}
+
+// statefulGuest's process returns the value it stored in memory during the
+// previous run, or 0 on the first run, and stores 42.
+var statefulGuest = testGuest("", `
+  (func (export "process") (result i32)
+    (local $prev i32)
+    (local.set $prev (i32.load (i32.const 512)))
+    (i32.store (i32.const 512) (i32.const 42))
+    (local.get $prev))
+`)
+
+func TestSnapshotRestore(t *testing.T) {
+	wm := newTestModule(t, statefulGuest)
+	assert.Error(t, wm.Restore())
+
+	wm.Snapshot()
+	size := len(wm.memory.Data())
+
+	rtn, err := wm.process()
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), rtn)
+
+	// Without Restore, state leaks into the next run.
+	rtn, err = wm.process()
+	require.NoError(t, err)
+	assert.Equal(t, int32(42), rtn)
+
+	// Grow memory and dirty the new page.
+	ptr, err := wm.malloc(2 * 65536)
+	require.NoError(t, err)
+	require.Greater(t, len(wm.memory.Data()), size)
+	b, err := wm.guestBytes(ptr+65536, 1)
+	require.NoError(t, err)
+	b[0] = 0xff
+
+	require.NoError(t, wm.Restore())
+	b, err = wm.guestBytes(ptr+65536, 1)
+	require.NoError(t, err)
+	assert.Zero(t, b[0])
+
+	rtn, err = wm.process()
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), rtn)
+}
+
+// listFieldsGuest exports list_fields which writes the field names buffer's
+// pointer and length at 0 and 4 and returns the host call's status.
+var listFieldsGuest = testGuest(`
+  (import "elastic" "elastic_list_fields" (func $list_fields (param i32 i32) (result i32)))
+`, `
+  (func (export "process") (result i32)
+    (i32.const 0))
+  (func (export "list_fields") (result i32)
+    (call $list_fields (i32.const 0) (i32.const 4)))
+`)
+
+func TestListFields(t *testing.T) {
+	wm := newTestModule(t, listFieldsGuest)
+	wm.SetEvent(map[string]interface{}{
+		"message": "hello",
+		"event": map[string]interface{}{
+			"kind":     "event",
+			"category": []interface{}{"network"},
+		},
+		"source": map[string]interface{}{
+			"geo": map[string]interface{}{"location": map[string]interface{}{"lat": 1.5, "lon": 2.5}},
+			"ip":  "10.0.0.1",
+		},
+		"labels": map[string]interface{}{},
+		"tags":   nil,
+	})
+
+	rtn, err := wm.CallExport("list_fields")
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+
+	var keys []string
+	require.NoError(t, json.Unmarshal([]byte(readGuestResult(t, wm, 0, 4)), &keys))
+	assert.Equal(t, []string{
+		"event.category",
+		"event.kind",
+		"labels",
+		"message",
+		"source.geo.location.lat",
+		"source.geo.location.lon",
+		"source.ip",
+		"tags",
+	}, keys)
+
+	wm.SetEvent(map[string]interface{}{})
+	rtn, err = wm.CallExport("list_fields")
+	require.NoError(t, err)
+	require.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, "[]", readGuestResult(t, wm, 0, 4))
+}
+
+func TestWriteMemory(t *testing.T) {
+	wm := newTestModule(t, putFieldGuest)
+	wm.SetEvent(map[string]interface{}{"message": "original"})
+	_, err := wm.process()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := wm.WriteMemory(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(wm.memory.Data()), n)
+	assert.Equal(t, wm.memory.Data(), buf.Bytes())
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("message")))
+}
+
+func TestNewWasmModuleFromSerialized(t *testing.T) {
+	wm := newTestModule(t, putFieldGuest)
+	data, err := wm.Serialize()
+	require.NoError(t, err)
+
+	restored, err := newWasmModuleFromSerialized(data)
+	require.NoError(t, err)
+	restored.SetEvent(map[string]interface{}{"message": "original"})
+
+	rtn, err := restored.process()
+	require.NoError(t, err)
+	assert.Equal(t, int32(StatusOK), rtn)
+	assert.Equal(t, "changed", restored.Event()["message"])
+
+	// Options apply to the deserialized module.
+	restored, err = newWasmModuleFromSerialized(data, WithReadOnlyEvent(false))
+	require.NoError(t, err)
+	restored.SetEvent(map[string]interface{}{"message": "original"})
+	rtn, err = restored.process()
+	require.NoError(t, err)
+	assert.Equal(t, int32(StatusInvalidArgument), rtn)
+
+	// Uncompiled WASM is not a serialized module.
+	wasmBytes, err := wasmer.Wat2Wasm(putFieldGuest)
+	require.NoError(t, err)
+	_, err = newWasmModuleFromSerialized(wasmBytes)
+	assert.ErrorContains(t, err, "same wasmer version and engine")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait after the last change before re-running.
+// Editors and compilers often write a file several times in quick succession.
+const watchDebounce = 250 * time.Millisecond
+
+// watchAndRun runs the module once and then again each time the -module or
+// -input files change. Errors from a run are printed and watching continues.
+func watchAndRun() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	paths := []string{modulePath}
+	if inputPath != "" {
+		paths = append(paths, inputPath)
+	}
+
+	// Watch the parent directories rather than the files so that files
+	// replaced by rename (as many editors and linkers do) are still seen.
+	files := map[string]struct{}{}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		files[abs] = struct{}{}
+		if err = watcher.Add(filepath.Dir(abs)); err != nil {
+			return err
+		}
+	}
+
+	rerun := func() {
+		event, err := run()
+		if err != nil {
+			log.Println("Error:", err)
+			return
+		}
+		printResult(event)
+	}
+
+	rerun()
+	watchLoop(watcher.Events, watcher.Errors, files, watchDebounce, rerun, nil)
+	return nil
+}
+
+// watchLoop calls rerun once changes to any of files have stopped for the
+// debounce duration. It returns when events is closed or done is signaled.
+func watchLoop(events <-chan fsnotify.Event, errs <-chan error, files map[string]struct{}, debounce time.Duration, rerun func(), done <-chan struct{}) {
+	timer := time.NewTimer(debounce)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if _, found := files[filepath.Clean(ev.Name)]; !found {
+				continue
+			}
+			timer.Reset(debounce)
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			log.Println("Watch error:", err)
+		case <-timer.C:
+			log.Println("Change detected. Re-running.")
+			rerun()
+		case <-done:
+			return
+		}
+	}
+}
+
+// printResult writes the event to stdout and, if -expected is set, any
+// differences from the expected event.
+func printResult(event map[string]interface{}) {
+	data, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		log.Println("Error:", err)
+		return
+	}
+	fmt.Println(string(data))
+
+	if expectedPath == "" {
+		return
+	}
+	if _, err = reportVerification(os.Stderr, event, expectedPath); err != nil {
+		log.Println("Failed to verify event:", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchLoop(t *testing.T) {
+	module, err := filepath.Abs("testdata/module.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]struct{}{module: {}}
+
+	events := make(chan fsnotify.Event)
+	errs := make(chan error)
+	done := make(chan struct{})
+	runs := make(chan struct{}, 10)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		watchLoop(events, errs, files, 50*time.Millisecond, func() { runs <- struct{}{} }, done)
+	}()
+
+	// A burst of writes to the module causes a single re-run.
+	for i := 0; i < 3; i++ {
+		events <- fsnotify.Event{Name: module, Op: fsnotify.Write}
+	}
+	// Other files and chmod events are ignored.
+	events <- fsnotify.Event{Name: filepath.Join(filepath.Dir(module), "other.wasm"), Op: fsnotify.Write}
+	events <- fsnotify.Event{Name: module, Op: fsnotify.Chmod}
+
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for re-run")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(done)
+	<-stopped
+	assert.Len(t, runs, 0, "expected a single re-run")
+}